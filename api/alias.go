@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+)
+
+func (api *SearchAPI) swapAlias(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	instanceID := vars["instance_id"]
+	dimension := vars["dimension"]
+
+	logData := log.Data{"instance_id": instanceID, "dimension": dimension}
+
+	swapRequest := &models.AliasSwapRequest{}
+	if err := json.NewDecoder(r.Body).Decode(swapRequest); err != nil {
+		log.Error(ctx, "swapAlias endpoint: failed to parse request body", err, logData)
+		setErrorCode(ctx, w, errs.ErrInvalidRequestBody)
+		return
+	}
+	logData["index"] = swapRequest.Index
+	logData["replace_legacy_index"] = swapRequest.ReplaceLegacyIndex
+
+	log.Info(ctx, "swapAlias endpoint: attempting to swap alias", logData)
+
+	swap, err := api.elasticsearch.SwapAlias(ctx, instanceID, dimension, swapRequest.Index, swapRequest.ReplaceLegacyIndex)
+	if err != nil {
+		log.Error(ctx, "swapAlias endpoint: failed to swap alias", err, logData)
+		setErrorCode(ctx, w, err)
+		return
+	}
+
+	writeAliasSwap(w, r, swap, logData)
+
+	log.Info(ctx, "swapAlias endpoint: alias swapped", logData)
+}
+
+func (api *SearchAPI) rollbackAlias(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	instanceID := vars["instance_id"]
+	dimension := vars["dimension"]
+
+	logData := log.Data{"instance_id": instanceID, "dimension": dimension}
+
+	log.Info(ctx, "rollbackAlias endpoint: attempting to roll back alias", logData)
+
+	swap, err := api.elasticsearch.RollbackAlias(ctx, instanceID, dimension)
+	if err != nil {
+		log.Error(ctx, "rollbackAlias endpoint: failed to roll back alias", err, logData)
+		setErrorCode(ctx, w, err)
+		return
+	}
+
+	writeAliasSwap(w, r, swap, logData)
+
+	log.Info(ctx, "rollbackAlias endpoint: alias rolled back", logData)
+}
+
+func writeAliasSwap(w http.ResponseWriter, r *http.Request, swap *models.AliasSwap, logData log.Data) {
+	ctx := r.Context()
+
+	logData["index"] = swap.Index
+	logData["previous_index"] = swap.PreviousIndex
+
+	b, err := json.Marshal(swap)
+	if err != nil {
+		log.Error(ctx, "failed to marshal alias swap into bytes", err, logData)
+		setErrorCode(ctx, w, errs.ErrInternalServer)
+		return
+	}
+
+	setJSONContentType(w)
+	if _, err = w.Write(b); err != nil {
+		log.Error(ctx, "error writing response", err, logData)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSwapAliasReturnsOK(t *testing.T) {
+	Convey("Given a versioned index exists for the alias return a status 200 (ok) with the previous index", t, func() {
+		testres := setupTest(testOpts{
+			method:        "PUT",
+			url:           "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate/alias",
+			body:          `{"index":"123_aggregate.2"}`,
+			reqHasAuth:    true,
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusOK)
+
+		swap := &models.AliasSwap{}
+		So(json.Unmarshal(testres.w.Body.Bytes(), swap), ShouldBeNil)
+		So(swap, ShouldResemble, &models.AliasSwap{Alias: "123_aggregate", Index: "123_aggregate.2", PreviousIndex: "123_aggregate.1"})
+	})
+}
+
+func TestSwapAliasReplacingLegacyIndexReturnsOK(t *testing.T) {
+	Convey("Given an index named as the alias exists and the request opts in to replacing it return a status 200 (ok)", t, func() {
+		testres := setupTest(testOpts{
+			method:        "PUT",
+			url:           "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate/alias",
+			body:          `{"index":"123_aggregate.2","replace_legacy_index":true}`,
+			reqHasAuth:    true,
+			privateSubnet: true,
+			legacyIndex:   true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusOK)
+	})
+}
+
+func TestFailToSwapAlias(t *testing.T) {
+	Convey("Given a request to swap an alias but no auth header is set return a status 401 (unauthorized)", t, func() {
+		testres := setupTest(testOpts{
+			method:        "PUT",
+			url:           "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate/alias",
+			body:          `{"index":"123_aggregate.2"}`,
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusUnauthorized)
+	})
+
+	Convey("Given a request to swap an alias with an invalid body return a status 400 (bad request)", t, func() {
+		testres := setupTest(testOpts{
+			method:        "PUT",
+			url:           "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate/alias",
+			body:          `{"index":`,
+			reqHasAuth:    true,
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusBadRequest)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrInvalidRequestBody.Error())
+	})
+
+	Convey("Given a request to swap an alias to an index which is not a version of the alias return a status 400 (bad request)", t, func() {
+		testres := setupTest(testOpts{
+			method:        "PUT",
+			url:           "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate/alias",
+			body:          `{"index":"456_geography.2"}`,
+			reqHasAuth:    true,
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusBadRequest)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrInvalidAliasTarget.Error())
+	})
+
+	Convey("Given a request to swap an alias to an index which does not exist return a status 404 (not found)", t, func() {
+		testres := setupTest(testOpts{
+			method:              "PUT",
+			url:                 "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate/alias",
+			body:                `{"index":"123_aggregate.2"}`,
+			reqHasAuth:          true,
+			privateSubnet:       true,
+			aliasTargetNotFound: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusNotFound)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrAliasTargetNotFound.Error())
+	})
+
+	Convey("Given a request to swap an alias when an index named as the alias exists return a status 409 (conflict)", t, func() {
+		testres := setupTest(testOpts{
+			method:        "PUT",
+			url:           "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate/alias",
+			body:          `{"index":"123_aggregate.2"}`,
+			reqHasAuth:    true,
+			privateSubnet: true,
+			legacyIndex:   true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusConflict)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrLegacyIndexExists.Error())
+	})
+
+	Convey("Given a request to swap an alias but unable to connect to elasticsearch return a status 500 (internal server error)", t, func() {
+		testres := setupTest(testOpts{
+			method:                "PUT",
+			url:                   "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate/alias",
+			body:                  `{"index":"123_aggregate.2"}`,
+			reqHasAuth:            true,
+			privateSubnet:         true,
+			esInternalServerError: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+}
+
+func TestRollbackAliasReturnsOK(t *testing.T) {
+	Convey("Given a previous versioned index exists for the alias return a status 200 (ok)", t, func() {
+		testres := setupTest(testOpts{
+			method:        "POST",
+			url:           "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate/alias/rollback",
+			reqHasAuth:    true,
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusOK)
+
+		swap := &models.AliasSwap{}
+		So(json.Unmarshal(testres.w.Body.Bytes(), swap), ShouldBeNil)
+		So(swap.Index, ShouldEqual, "123_aggregate.1")
+		So(swap.PreviousIndex, ShouldEqual, "123_aggregate.2")
+	})
+}
+
+func TestFailToRollbackAlias(t *testing.T) {
+	Convey("Given no previous versioned index exists for the alias return a status 409 (conflict)", t, func() {
+		testres := setupTest(testOpts{
+			method:          "POST",
+			url:             "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate/alias/rollback",
+			reqHasAuth:      true,
+			privateSubnet:   true,
+			noPreviousIndex: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusConflict)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrNoPreviousIndex.Error())
+	})
+
+	Convey("Given private endpoints are disabled, a request to roll back an alias returns a status 404 (not found)", t, func() {
+		testres := setupTest(testOpts{
+			method:     "POST",
+			url:        "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate/alias/rollback",
+			reqHasAuth: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusNotFound)
+	})
+}
@@ -5,12 +5,14 @@ import (
 	"net/url"
 
 	"github.com/ONSdigital/dp-api-clients-go/dataset"
+	"github.com/ONSdigital/dp-api-clients-go/hierarchy"
 	"github.com/ONSdigital/dp-api-clients-go/middleware"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 
 	identityclient "github.com/ONSdigital/dp-api-clients-go/identity"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
 	"github.com/ONSdigital/dp-dimension-search-api/searchoutputqueue"
 	dphandlers "github.com/ONSdigital/dp-net/handlers"
 	"github.com/ONSdigital/dp-net/http"
@@ -22,7 +24,15 @@ import (
 var httpServer *http.Server
 
 type DatasetAPIClient interface {
+	Get(ctx context.Context, userAuthToken, serviceAuthToken, collectionID, datasetID string) (m dataset.DatasetDetails, err error)
+	GetEdition(ctx context.Context, userAuthToken, serviceAuthToken, collectionID, datasetID, edition string) (m dataset.Edition, err error)
 	GetVersion(ctx context.Context, userAuthToken, serviceAuthToken, downloadServiceAuthToken, collectionID, datasetID, edition, version string) (m dataset.Version, err error)
+	GetInstance(ctx context.Context, userAuthToken, serviceAuthToken, collectionID, instanceID string) (m dataset.Instance, err error)
+}
+
+// HierarchyAPIClient - An interface used to check a dimension has a hierarchy to index
+type HierarchyAPIClient interface {
+	GetRoot(ctx context.Context, instanceID, name string) (hierarchy.Model, error)
 }
 
 // DownloadsGenerator pre generates full file downloads for the specified dataset/edition/version
@@ -35,6 +45,37 @@ type OutputQueue interface {
 	Queue(ctx context.Context, output *searchoutputqueue.Search) error
 }
 
+// DeduplicatingOutputQueue - An interface implemented by output queues which coalesce repeated search outputs
+type DeduplicatingOutputQueue interface {
+	QueueOnce(ctx context.Context, output *searchoutputqueue.Search, force bool) (coalesced bool, err error)
+}
+
+// PrioritisingOutputQueue - An interface implemented by output queues which queue high priority search outputs separately
+type PrioritisingOutputQueue interface {
+	QueuesHighPriority() bool
+}
+
+// wrappedOutputQueue - An interface implemented by output queues which sit in front of another output queue
+type wrappedOutputQueue interface {
+	Unwrap() searchoutputqueue.Queuer
+}
+
+// OutputQueueInspector - An interface implemented by output queues which hold search outputs in memory
+type OutputQueueInspector interface {
+	Searches() []searchoutputqueue.Search
+	Clear()
+}
+
+// OrphanCollector - An interface used to find and remove orphaned search indexes
+type OrphanCollector interface {
+	Collect(ctx context.Context, dryRun bool) (*models.OrphanReport, error)
+}
+
+// MissingIndexReconciler - An interface used to find and rebuild missing search indexes
+type MissingIndexReconciler interface {
+	Reconcile(ctx context.Context, dryRun bool) (*models.MissingIndexReport, error)
+}
+
 type HealthCheck interface {
 	Start(ctx context.Context)
 	Stop()
@@ -42,22 +83,27 @@ type HealthCheck interface {
 
 // SearchAPI manages searches across indices
 type SearchAPI struct {
-	datasetAPIClient    DatasetAPIClient
-	serviceAuthToken    string
-	defaultMaxResults   int
-	elasticsearch       Elasticsearcher
-	hasPrivateEndpoints bool
-	host                *url.URL
-	router              *mux.Router
-	searchOutputQueue   OutputQueue
-	enableURLRewriting  bool
+	datasetAPIClient       DatasetAPIClient
+	serviceAuthToken       string
+	defaultMaxResults      int
+	elasticsearch          Elasticsearcher
+	hasPrivateEndpoints    bool
+	hierarchyAPIClient     HierarchyAPIClient
+	missingIndexReconciler MissingIndexReconciler
+	orphanCollector        OrphanCollector
+	outputQueueInspector   OutputQueueInspector
+	queuesHighPriority     bool
+	host                   *url.URL
+	router                 *mux.Router
+	searchOutputQueue      OutputQueue
+	enableURLRewriting     bool
 }
 
 // CreateSearchAPI manages all the routes configured to API
 func CreateSearchAPI(ctx context.Context,
 	host *url.URL, bindAddr, authAPIURL string, errorChan chan error, searchOutputQueue OutputQueue,
-	datasetAPIClient DatasetAPIClient, serviceAuthToken string, elasticsearch Elasticsearcher,
-	defaultMaxResults int, hasPrivateEndpoints bool,
+	datasetAPIClient DatasetAPIClient, hierarchyAPIClient HierarchyAPIClient, serviceAuthToken string, elasticsearch Elasticsearcher,
+	orphanCollector OrphanCollector, missingIndexReconciler MissingIndexReconciler, defaultMaxResults int, hasPrivateEndpoints bool,
 	healthCheck *healthcheck.HealthCheck, oTServiceName string, enableURLRewriting bool) {
 	router := mux.NewRouter()
 	router.Use(otelmux.Middleware(oTServiceName))
@@ -65,8 +111,11 @@ func CreateSearchAPI(ctx context.Context,
 		router,
 		searchOutputQueue,
 		datasetAPIClient,
+		hierarchyAPIClient,
 		serviceAuthToken,
 		elasticsearch,
+		orphanCollector,
+		missingIndexReconciler,
 		defaultMaxResults,
 		hasPrivateEndpoints,
 		healthCheck,
@@ -101,22 +150,28 @@ func routes(host *url.URL,
 	router *mux.Router,
 	searchOutputQueue OutputQueue,
 	datasetAPIClient DatasetAPIClient,
+	hierarchyAPIClient HierarchyAPIClient,
 	serviceAuthToken string,
 	elasticsearch Elasticsearcher,
+	orphanCollector OrphanCollector,
+	missingIndexReconciler MissingIndexReconciler,
 	defaultMaxResults int,
 	hasPrivateEndpoints bool,
 	healthCheck *healthcheck.HealthCheck,
 	enableURLRewriting bool) *SearchAPI {
 	api := SearchAPI{
-		datasetAPIClient:    datasetAPIClient,
-		serviceAuthToken:    serviceAuthToken,
-		defaultMaxResults:   defaultMaxResults,
-		elasticsearch:       elasticsearch,
-		hasPrivateEndpoints: hasPrivateEndpoints,
-		searchOutputQueue:   searchOutputQueue,
-		host:                host,
-		router:              router,
-		enableURLRewriting:  enableURLRewriting,
+		datasetAPIClient:       datasetAPIClient,
+		serviceAuthToken:       serviceAuthToken,
+		defaultMaxResults:      defaultMaxResults,
+		elasticsearch:          elasticsearch,
+		hasPrivateEndpoints:    hasPrivateEndpoints,
+		hierarchyAPIClient:     hierarchyAPIClient,
+		missingIndexReconciler: missingIndexReconciler,
+		orphanCollector:        orphanCollector,
+		searchOutputQueue:      searchOutputQueue,
+		host:                   host,
+		router:                 router,
+		enableURLRewriting:     enableURLRewriting,
 	}
 
 	api.router.HandleFunc("/health", healthCheck.Handler)
@@ -125,6 +180,30 @@ func routes(host *url.URL,
 	if hasPrivateEndpoints {
 		api.router.HandleFunc("/dimension-search/instances/{instance_id}/dimensions/{dimension}", dphandlers.CheckIdentity(api.createSearchIndex)).Methods("PUT")
 		api.router.HandleFunc("/dimension-search/instances/{instance_id}/dimensions/{dimension}", dphandlers.CheckIdentity(api.deleteSearchIndex)).Methods("DELETE")
+		api.router.HandleFunc("/dimension-search/instances/{instance_id}/dimensions/{dimension}/alias", dphandlers.CheckIdentity(api.swapAlias)).Methods("PUT")
+		api.router.HandleFunc("/dimension-search/instances/{instance_id}/dimensions/{dimension}/alias/rollback", dphandlers.CheckIdentity(api.rollbackAlias)).Methods("POST")
+		api.router.HandleFunc("/dimension-search/index-template", dphandlers.CheckIdentity(api.checkIndexTemplate)).Methods("GET")
+		api.router.HandleFunc("/dimension-search/index-template", dphandlers.CheckIdentity(api.installIndexTemplate)).Methods("PUT")
+		api.router.HandleFunc("/dimension-search/reconcile/orphaned-indexes", dphandlers.CheckIdentity(api.collectOrphanedIndexes)).Methods("POST")
+		api.router.HandleFunc("/dimension-search/reconcile/missing-indexes", dphandlers.CheckIdentity(api.reconcileMissingIndexes)).Methods("POST")
+
+		// only the in-memory output queue, used for local development and component tests, can be inspected
+		var queue searchoutputqueue.Queuer = searchOutputQueue
+		for {
+			if prioritising, ok := queue.(PrioritisingOutputQueue); ok && prioritising.QueuesHighPriority() {
+				api.queuesHighPriority = true
+			}
+			wrapper, ok := queue.(wrappedOutputQueue)
+			if !ok {
+				break
+			}
+			queue = wrapper.Unwrap()
+		}
+		if inspector, ok := queue.(OutputQueueInspector); ok {
+			api.outputQueueInspector = inspector
+			api.router.HandleFunc("/dimension-search/output-queue", dphandlers.CheckIdentity(api.getOutputQueue)).Methods("GET")
+			api.router.HandleFunc("/dimension-search/output-queue", dphandlers.CheckIdentity(api.clearOutputQueue)).Methods("DELETE")
+		}
 	}
 
 	return &api
@@ -9,5 +9,9 @@ import (
 // Elasticsearcher - An interface used to access elasticsearch
 type Elasticsearcher interface {
 	DeleteSearchIndex(ctx context.Context, instanceID, dimension string) (int, error)
-	QuerySearchIndex(ctx context.Context, instanceID, dimension, term string, limit, offset int) (*models.SearchResponse, int, error)
+	QuerySearchIndex(ctx context.Context, instanceID, dimension, term string, limit, offset int, exactTotal bool) (*models.SearchResponse, int, error)
+	SwapAlias(ctx context.Context, instanceID, dimension, index string, replaceLegacy bool) (*models.AliasSwap, error)
+	RollbackAlias(ctx context.Context, instanceID, dimension string) (*models.AliasSwap, error)
+	CheckTemplate(ctx context.Context) (*models.IndexTemplateReport, error)
+	InstallTemplate(ctx context.Context) (*models.IndexTemplateReport, error)
 }
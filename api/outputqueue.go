@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+func (api *SearchAPI) getOutputQueue(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	searches := api.outputQueueInspector.Searches()
+	logData := log.Data{"queued": len(searches)}
+
+	b, err := json.Marshal(searches)
+	if err != nil {
+		log.Error(ctx, "getOutputQueue endpoint: failed to marshal queued search outputs into bytes", err, logData)
+		setErrorCode(ctx, w, errs.ErrInternalServer)
+		return
+	}
+
+	setJSONContentType(w)
+	if _, err = w.Write(b); err != nil {
+		log.Error(ctx, "error writing response", err, logData)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info(ctx, "getOutputQueue endpoint: returned queued search outputs", logData)
+}
+
+func (api *SearchAPI) clearOutputQueue(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	api.outputQueueInspector.Clear()
+
+	w.WriteHeader(http.StatusNoContent)
+
+	log.Info(ctx, "clearOutputQueue endpoint: cleared queued search outputs")
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-api/mocks"
+	"github.com/ONSdigital/dp-dimension-search-api/searchoutputqueue"
+	"github.com/ONSdigital/dp-net/request"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func setupOutputQueueTest(outputQueue OutputQueue, method string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "http://localhost:23100/dimension-search/output-queue", http.NoBody)
+	r = r.WithContext(request.SetCaller(r.Context(), "APIAmWhoAPIAm"))
+	w := httptest.NewRecorder()
+
+	api := routes(host, mux.NewRouter(), outputQueue, &mocks.DatasetAPI{}, &mocks.HierarchyAPI{}, "1234", &mocks.Elasticsearch{}, &mocks.OrphanCollector{}, &mocks.MissingIndexReconciler{}, defaultMaxResults, true, nil, false)
+	api.router.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestInspectOutputQueue(t *testing.T) {
+	Convey("Given search outputs have been queued in memory", t, func() {
+		outputQueue := searchoutputqueue.CreateMemoryQueue()
+		So(outputQueue.Queue(t.Context(), &searchoutputqueue.Search{InstanceID: "123", Dimension: "aggregate"}), ShouldBeNil)
+
+		Convey("When the output queue is inspected return a status 200 (ok) with the queued search outputs", func() {
+			w := setupOutputQueueTest(outputQueue, "GET")
+			So(w.Code, ShouldEqual, http.StatusOK)
+
+			var searches []searchoutputqueue.Search
+			So(json.Unmarshal(w.Body.Bytes(), &searches), ShouldBeNil)
+			So(searches, ShouldResemble, []searchoutputqueue.Search{{InstanceID: "123", Dimension: "aggregate"}})
+		})
+
+		Convey("When the output queue is cleared return a status 204 (no content) and remove the queued search outputs", func() {
+			w := setupOutputQueueTest(outputQueue, "DELETE")
+			So(w.Code, ShouldEqual, http.StatusNoContent)
+			So(outputQueue.Searches(), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given search outputs are queued somewhere other than memory the output queue cannot be inspected", t, func() {
+		w := setupOutputQueueTest(&mocks.BuildSearch{}, "GET")
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+	})
+}
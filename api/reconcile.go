@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+func (api *SearchAPI) collectOrphanedIndexes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// only delete orphaned indexes when explicitly asked to
+	dryRun := r.FormValue("dry_run") != "false"
+
+	logData := log.Data{"dry_run": dryRun}
+
+	log.Info(ctx, "collectOrphanedIndexes endpoint: attempting to find orphaned search indexes", logData)
+
+	report, err := api.orphanCollector.Collect(ctx, dryRun)
+	if err != nil {
+		log.Error(ctx, "collectOrphanedIndexes endpoint: failed to collect orphaned search indexes", err, logData)
+		setErrorCode(ctx, w, err)
+		return
+	}
+
+	logData["orphans"] = len(report.Orphans)
+	logData["deleted"] = report.Deleted
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		log.Error(ctx, "collectOrphanedIndexes endpoint: failed to marshal report into bytes", err, logData)
+		setErrorCode(ctx, w, errs.ErrInternalServer)
+		return
+	}
+
+	setJSONContentType(w)
+	if _, err = w.Write(b); err != nil {
+		log.Error(ctx, "error writing response", err, logData)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info(ctx, "collectOrphanedIndexes endpoint: orphaned search index collection complete", logData)
+}
+
+func (api *SearchAPI) reconcileMissingIndexes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// only queue rebuilds when explicitly asked to
+	dryRun := r.FormValue("dry_run") != "false"
+
+	logData := log.Data{"dry_run": dryRun}
+
+	log.Info(ctx, "reconcileMissingIndexes endpoint: attempting to find missing search indexes", logData)
+
+	report, err := api.missingIndexReconciler.Reconcile(ctx, dryRun)
+	if err != nil {
+		log.Error(ctx, "reconcileMissingIndexes endpoint: failed to reconcile missing search indexes", err, logData)
+		setErrorCode(ctx, w, err)
+		return
+	}
+
+	logData["missing"] = len(report.Missing)
+	logData["queued"] = report.Queued
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		log.Error(ctx, "reconcileMissingIndexes endpoint: failed to marshal report into bytes", err, logData)
+		setErrorCode(ctx, w, errs.ErrInternalServer)
+		return
+	}
+
+	setJSONContentType(w)
+	if report.Queued {
+		// rebuilds are queued in the background at a limited rate
+		w.WriteHeader(http.StatusAccepted)
+	}
+	if _, err = w.Write(b); err != nil {
+		log.Error(ctx, "error writing response", err, logData)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info(ctx, "reconcileMissingIndexes endpoint: missing search index reconciliation complete", logData)
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCollectOrphanedIndexesReturnsOK(t *testing.T) {
+	Convey("Given a request to collect orphaned indexes without a dry_run parameter, orphans are reported but not deleted", t, func() {
+		testres := setupTest(testOpts{
+			method:        "POST",
+			url:           "http://localhost:23100/dimension-search/reconcile/orphaned-indexes",
+			reqHasAuth:    true,
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusOK)
+		So(testres.orphanCollectorMock.Calls, ShouldEqual, 1)
+		So(testres.orphanCollectorMock.DryRun, ShouldBeTrue)
+
+		report := &models.OrphanReport{}
+		So(json.Unmarshal(testres.w.Body.Bytes(), report), ShouldBeNil)
+		So(report.DryRun, ShouldBeTrue)
+		So(report.Deleted, ShouldEqual, 0)
+		So(len(report.Orphans), ShouldEqual, 1)
+	})
+
+	Convey("Given a request to collect orphaned indexes with dry_run=false, orphans are deleted", t, func() {
+		testres := setupTest(testOpts{
+			method:        "POST",
+			url:           "http://localhost:23100/dimension-search/reconcile/orphaned-indexes?dry_run=false",
+			reqHasAuth:    true,
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusOK)
+		So(testres.orphanCollectorMock.DryRun, ShouldBeFalse)
+
+		report := &models.OrphanReport{}
+		So(json.Unmarshal(testres.w.Body.Bytes(), report), ShouldBeNil)
+		So(report.Deleted, ShouldEqual, 1)
+		So(report.Orphans[0].Deleted, ShouldBeTrue)
+	})
+}
+
+func TestFailToCollectOrphanedIndexes(t *testing.T) {
+	Convey("Given a request to collect orphaned indexes but no auth header is set return a status 401 (unauthorized)", t, func() {
+		testres := setupTest(testOpts{
+			method:        "POST",
+			url:           "http://localhost:23100/dimension-search/reconcile/orphaned-indexes",
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusUnauthorized)
+		So(testres.orphanCollectorMock.Calls, ShouldEqual, 0)
+	})
+
+	Convey("Given a request to collect orphaned indexes but unable to list indexes return a status 500 (internal server error)", t, func() {
+		testres := setupTest(testOpts{
+			method:               "POST",
+			url:                  "http://localhost:23100/dimension-search/reconcile/orphaned-indexes",
+			reqHasAuth:           true,
+			privateSubnet:        true,
+			orphanCollectorError: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusInternalServerError)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrInternalServer.Error())
+	})
+
+	Convey("Given private endpoints are disabled, a request to collect orphaned indexes returns a status 404 (not found)", t, func() {
+		testres := setupTest(testOpts{
+			method:     "POST",
+			url:        "http://localhost:23100/dimension-search/reconcile/orphaned-indexes",
+			reqHasAuth: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusNotFound)
+	})
+}
+
+func TestReconcileMissingIndexesReturnsOK(t *testing.T) {
+	Convey("Given a request to reconcile missing indexes without a dry_run parameter, missing indexes are reported but not queued", t, func() {
+		testres := setupTest(testOpts{
+			method:        "POST",
+			url:           "http://localhost:23100/dimension-search/reconcile/missing-indexes",
+			reqHasAuth:    true,
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusOK)
+		So(testres.reconcilerMock.Calls, ShouldEqual, 1)
+		So(testres.reconcilerMock.DryRun, ShouldBeTrue)
+
+		report := &models.MissingIndexReport{}
+		So(json.Unmarshal(testres.w.Body.Bytes(), report), ShouldBeNil)
+		So(report.Queued, ShouldBeFalse)
+		So(len(report.Missing), ShouldEqual, 1)
+	})
+
+	Convey("Given a request to reconcile missing indexes with dry_run=false, rebuilds are queued and a status 202 (accepted) is returned", t, func() {
+		testres := setupTest(testOpts{
+			method:        "POST",
+			url:           "http://localhost:23100/dimension-search/reconcile/missing-indexes?dry_run=false",
+			reqHasAuth:    true,
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusAccepted)
+		So(testres.reconcilerMock.DryRun, ShouldBeFalse)
+
+		report := &models.MissingIndexReport{}
+		So(json.Unmarshal(testres.w.Body.Bytes(), report), ShouldBeNil)
+		So(report.Queued, ShouldBeTrue)
+	})
+}
+
+func TestFailToReconcileMissingIndexes(t *testing.T) {
+	Convey("Given a request to reconcile missing indexes but no auth header is set return a status 401 (unauthorized)", t, func() {
+		testres := setupTest(testOpts{
+			method:        "POST",
+			url:           "http://localhost:23100/dimension-search/reconcile/missing-indexes",
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusUnauthorized)
+		So(testres.reconcilerMock.Calls, ShouldEqual, 0)
+	})
+
+	Convey("Given a request to reconcile missing indexes while rebuilds are still being queued return a status 409 (conflict)", t, func() {
+		testres := setupTest(testOpts{
+			method:               "POST",
+			url:                  "http://localhost:23100/dimension-search/reconcile/missing-indexes?dry_run=false",
+			reqHasAuth:           true,
+			privateSubnet:        true,
+			reconcilerInProgress: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusConflict)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrReconciliationInProgress.Error())
+	})
+
+	Convey("Given a request to reconcile missing indexes but the dataset API is unavailable return a status 500 (internal server error)", t, func() {
+		testres := setupTest(testOpts{
+			method:          "POST",
+			url:             "http://localhost:23100/dimension-search/reconcile/missing-indexes",
+			reqHasAuth:      true,
+			privateSubnet:   true,
+			reconcilerError: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+}
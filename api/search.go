@@ -3,10 +3,13 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/ONSdigital/dp-api-clients-go/dataset"
 	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
 	"github.com/ONSdigital/dp-dimension-search-api/models"
 	"github.com/ONSdigital/dp-dimension-search-api/searchoutputqueue"
@@ -20,12 +23,15 @@ const (
 	defaultLimit  = 50
 	defaultOffset = 0
 
-	datasetNotFound = "dataset not found"
-	editionNotFound = "edition not found"
-	versionNotFound = "version not found"
+	internalError = "internal server error"
 
-	internalError         = "internal server error"
-	exceedsDefaultMaximum = "the maximum offset has been reached, the offset cannot be more than"
+	// retryAfter is the number of seconds a client is asked to wait before retrying when search index builds cannot
+	// be queued, or a search index is being built
+	retryAfter = "5"
+
+	// states of the task of an import building the search index of a dimension before it has completed
+	buildTaskCreated   = "created"
+	buildTaskSubmitted = "submitted"
 )
 
 func (api *SearchAPI) getSearch(w http.ResponseWriter, r *http.Request) {
@@ -40,6 +46,7 @@ func (api *SearchAPI) getSearch(w http.ResponseWriter, r *http.Request) {
 	term := r.FormValue("q")
 	requestedLimit := r.FormValue("limit")
 	requestedOffset := r.FormValue("offset")
+	requestedExactTotal := r.FormValue("exact_totalcount")
 
 	logData := log.Data{
 		"dataset_id":       datasetID,
@@ -49,6 +56,7 @@ func (api *SearchAPI) getSearch(w http.ResponseWriter, r *http.Request) {
 		"query_term":       term,
 		"requested_limit":  requestedLimit,
 		"requested_offset": requestedOffset,
+		"exact_totalcount": requestedExactTotal,
 	}
 
 	log.Info(ctx, "getSearch endpoint: incoming request", logData)
@@ -64,7 +72,7 @@ func (api *SearchAPI) getSearch(w http.ResponseWriter, r *http.Request) {
 	versionDoc, err := api.datasetAPIClient.GetVersion(ctx, "", serviceAuthToken, "", "", datasetID, edition, version)
 	if err != nil {
 		log.Error(ctx, "getSearch endpoint: failed to get version of a dataset from the dataset API", err, logData)
-		setErrorCode(w, setError(err))
+		setErrorCode(ctx, w, api.versionError(ctx, err, serviceAuthToken, datasetID, edition))
 		return
 	}
 
@@ -78,7 +86,7 @@ func (api *SearchAPI) getSearch(w http.ResponseWriter, r *http.Request) {
 		limit, err = strconv.Atoi(requestedLimit)
 		if err != nil {
 			log.Error(ctx, "getSearch endpoint: request limit parameter error", err, logData)
-			setErrorCode(w, errs.ErrParsingQueryParameters)
+			setErrorCode(ctx, w, errs.ErrParsingQueryParameters)
 			return
 		}
 	}
@@ -88,7 +96,17 @@ func (api *SearchAPI) getSearch(w http.ResponseWriter, r *http.Request) {
 		offset, err = strconv.Atoi(requestedOffset)
 		if err != nil {
 			log.Error(ctx, "getSearch endpoint: request offset parameter error", err, logData)
-			setErrorCode(w, errs.ErrParsingQueryParameters)
+			setErrorCode(ctx, w, errs.ErrParsingQueryParameters)
+			return
+		}
+	}
+
+	exactTotal := false
+	if requestedExactTotal != "" {
+		exactTotal, err = strconv.ParseBool(requestedExactTotal)
+		if err != nil {
+			log.Error(ctx, "getSearch endpoint: request exact_totalcount parameter error", err, logData)
+			setErrorCode(ctx, w, errs.ErrInvalidExactTotalCount)
 			return
 		}
 	}
@@ -101,7 +119,7 @@ func (api *SearchAPI) getSearch(w http.ResponseWriter, r *http.Request) {
 
 	if err = page.ValidateQueryParameters(term); err != nil {
 		log.Error(ctx, "getSearch endpoint: request offset parameter error", err, logData)
-		setErrorCode(w, setError(err))
+		setErrorCode(ctx, w, err)
 		return
 	}
 
@@ -110,17 +128,24 @@ func (api *SearchAPI) getSearch(w http.ResponseWriter, r *http.Request) {
 
 	log.Info(ctx, "getSearch endpoint: just before querying search index", logData)
 
-	response, _, err := api.elasticsearch.QuerySearchIndex(ctx, instanceID, dimension, term, page.Limit, page.Offset)
+	response, _, err := api.elasticsearch.QuerySearchIndex(ctx, instanceID, dimension, term, page.Limit, page.Offset, exactTotal)
 	if err != nil {
+		if errors.Is(err, errs.ErrDimensionNotSearchable) && searchIndexBuilding(versionDoc, dimension) {
+			err = errs.ErrSearchIndexBuilding
+		}
 		log.Error(ctx, "getSearch endpoint: failed to query elastic search index", err, logData)
-		setErrorCode(w, setError(err))
+		setErrorCode(ctx, w, err)
 		return
 	}
 
 	searchResults := &models.SearchResults{
-		TotalCount: response.Hits.Total.Value,
-		Limit:      page.Limit,
-		Offset:     page.Offset,
+		TotalCount:         response.Hits.Total.Value,
+		TotalCountRelation: response.Hits.Total.Relation,
+		Limit:              page.Limit,
+		Offset:             page.Offset,
+	}
+	if searchResults.TotalCountRelation == "" {
+		searchResults.TotalCountRelation = models.TotalCountExact
 	}
 	if api.enableURLRewriting {
 		dimensionSearchAPILinksBuilder := links.FromHeadersOrDefault(&r.Header, api.host)
@@ -153,7 +178,7 @@ func (api *SearchAPI) getSearch(w http.ResponseWriter, r *http.Request) {
 	b, err := json.Marshal(searchResults)
 	if err != nil {
 		log.Error(ctx, "getSearch endpoint: failed to marshal search resource into bytes", err, logData)
-		setErrorCode(w, errs.ErrInternalServer)
+		setErrorCode(ctx, w, errs.ErrInternalServer)
 		return
 	}
 
@@ -241,18 +266,70 @@ func (api *SearchAPI) createSearchIndex(w http.ResponseWriter, r *http.Request)
 
 	log.Info(ctx, "createSearchIndex endpoint: attempting to enqueue a new search index", logData)
 
+	priority := r.FormValue("priority")
+	switch priority {
+	case "":
+		priority = searchoutputqueue.PriorityNormal
+	case searchoutputqueue.PriorityNormal, searchoutputqueue.PriorityHigh:
+	default:
+		logData["priority"] = priority
+		log.Error(ctx, "createSearchIndex endpoint: invalid priority", errs.ErrInvalidPriority, logData)
+		setErrorCode(ctx, w, errs.ErrInvalidPriority)
+		return
+	}
+	if priority == searchoutputqueue.PriorityHigh && !api.queuesHighPriority {
+		log.Warn(ctx, "createSearchIndex endpoint: no priority topic is configured, queueing with normal priority", logData)
+		priority = searchoutputqueue.PriorityNormal
+	}
+	logData["priority"] = priority
+
+	if err := api.validateInstanceDimension(ctx, instanceID, dimension); err != nil {
+		log.Error(ctx, "createSearchIndex endpoint: instance dimension cannot be indexed", err, logData)
+		setErrorCode(ctx, w, err)
+		return
+	}
+
 	output := &searchoutputqueue.Search{
 		Dimension:  dimension,
 		InstanceID: instanceID,
 	}
+	if priority == searchoutputqueue.PriorityHigh {
+		output.Priority = priority
+	}
+
+	// force bypasses the coalescing of requests identical to one recently queued
+	force := r.FormValue("force") == "true"
+	logData["force"] = force
+
+	build := &models.SearchIndexBuild{Dimension: dimension, InstanceID: instanceID, Priority: priority}
+
+	var err error
+	if dedup, ok := api.searchOutputQueue.(DeduplicatingOutputQueue); ok {
+		build.Coalesced, err = dedup.QueueOnce(ctx, output, force)
+	} else {
+		err = api.searchOutputQueue.Queue(ctx, output)
+	}
+	if err != nil {
+		log.Error(ctx, "createSearchIndex endpoint: failed to queue search index", err, logData)
+		setErrorCode(ctx, w, err)
+		return
+	}
+
+	logData["coalesced"] = build.Coalesced
 
-	if err := api.searchOutputQueue.Queue(ctx, output); err != nil {
-		setErrorCode(w, err)
+	b, err := json.Marshal(build)
+	if err != nil {
+		log.Error(ctx, "createSearchIndex endpoint: failed to marshal search index build into bytes", err, logData)
+		setErrorCode(ctx, w, errs.ErrInternalServer)
 		return
 	}
 
 	setJSONContentType(w)
-	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(b); err != nil {
+		log.Error(ctx, "error writing response", err, logData)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	log.Info(ctx, "createSearchIndex endpoint: index creation queued", logData)
 }
@@ -271,7 +348,7 @@ func (api *SearchAPI) deleteSearchIndex(w http.ResponseWriter, r *http.Request)
 	status, err := api.elasticsearch.DeleteSearchIndex(ctx, instanceID, dimension)
 	logData["status"] = status
 	if err != nil {
-		setErrorCode(w, err)
+		setErrorCode(ctx, w, err)
 		return
 	}
 
@@ -281,34 +358,59 @@ func (api *SearchAPI) deleteSearchIndex(w http.ResponseWriter, r *http.Request)
 	log.Info(ctx, "deleteSearchIndex endpoint: search index deleted", logData)
 }
 
+// searchIndexBuilding reports whether the import of a version is still building the search index of a dimension,
+// as recorded by the dataset API, so that every instance of this service agrees
+func searchIndexBuilding(version dataset.Version, dimension string) bool {
+	if version.ImportTasks == nil {
+		return false
+	}
+	for _, task := range version.ImportTasks.BuildSearchIndexTasks {
+		if task != nil && task.DimensionName == dimension {
+			return task.State == buildTaskCreated || task.State == buildTaskSubmitted
+		}
+	}
+	return false
+}
+
 func setJSONContentType(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
 }
 
-func setError(err error) (searchError error) {
-	switch {
-	case strings.Contains(err.Error(), versionNotFound):
-		searchError = errs.ErrVersionNotFound
-	case strings.Contains(err.Error(), editionNotFound):
-		searchError = errs.ErrEditionNotFound
-	case strings.Contains(err.Error(), datasetNotFound):
-		searchError = errs.ErrDatasetNotFound
-	default:
-		searchError = err
+// versionError maps an error getting a version from the dataset API to the error responded with. The dataset API
+// responds with 404 when the dataset, edition or version is not found, or is not published, so the dataset and
+// edition are requested to tell which was not found.
+func (api *SearchAPI) versionError(ctx context.Context, err error, serviceAuthToken, datasetID, edition string) error {
+	if !errs.IsNotFound(err) {
+		return err
 	}
 
-	return searchError
+	if _, err = api.datasetAPIClient.Get(ctx, "", serviceAuthToken, "", datasetID); errs.IsNotFound(err) {
+		return errs.ErrDatasetNotFound
+	}
+	if _, err = api.datasetAPIClient.GetEdition(ctx, "", serviceAuthToken, "", datasetID, edition); errs.IsNotFound(err) {
+		return errs.ErrEditionNotFound
+	}
+	return errs.ErrVersionNotFound
 }
 
-func setErrorCode(w http.ResponseWriter, err error) {
-	switch {
-	case errs.NotFoundMap[err]:
-		http.Error(w, err.Error(), http.StatusNotFound)
-	case errs.BadRequestMap[err]:
-		http.Error(w, err.Error(), http.StatusBadRequest)
-	case strings.Contains(err.Error(), exceedsDefaultMaximum):
-		http.Error(w, err.Error(), http.StatusBadRequest)
-	default:
+// setErrorCode responds with the status and message of the API error err is, or wraps, logging its code. Any other
+// error, and any internal error, is responded to with 500 and a generic message.
+func setErrorCode(ctx context.Context, w http.ResponseWriter, err error) {
+	apiErr := errs.ErrInternalServer
+	errors.As(err, &apiErr)
+	log.Info(ctx, "responding with error", log.Data{"status": apiErr.Status, "error_code": apiErr.Code})
+
+	if apiErr.Status == http.StatusInternalServerError {
 		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	var retry *errs.RetryAfterError
+	switch {
+	case errors.As(err, &retry):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.After.Seconds()))))
+	case errors.Is(err, errs.ErrQueueUnavailable), errors.Is(err, errs.ErrSearchIndexBuilding):
+		w.Header().Set("Retry-After", retryAfter)
 	}
+	http.Error(w, apiErr.Error(), apiErr.Status)
 }
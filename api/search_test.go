@@ -4,17 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ONSdigital/dp-api-clients-go/dataset"
 	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
 	"github.com/ONSdigital/dp-dimension-search-api/mocks"
 	"github.com/ONSdigital/dp-dimension-search-api/models"
+	"github.com/ONSdigital/dp-dimension-search-api/searchoutputqueue"
 	"github.com/ONSdigital/dp-net/request"
 	"github.com/gorilla/mux"
 	. "github.com/smartystreets/goconvey/convey"
@@ -28,37 +33,75 @@ var (
 type testOpts struct {
 	method                string
 	url                   string
+	body                  string
 	serviceAuthToken      string
 	maxResults            int
 	dsInternalServerError bool
 	dsRequireNoAuth       bool
 	dsRequireAuth         bool
 	dsVersionNotFound     bool
+	dsDatasetNotFound     bool
+	dsEditionNotFound     bool
 	esIndexNotFound       bool
 	esInternalServerError bool
+	esUnavailable         bool
+	esTimedOut            bool
+	esTotalCapped         bool
 	reqHasAuth            bool
 	searchReturnError     bool
 	privateSubnet         bool
 	enableURLRewriting    bool
 	externalRequest       bool
+	orphanCollectorError  bool
+	reconcilerError       bool
+	reconcilerInProgress  bool
+	aliasTargetNotFound   bool
+	legacyIndex           bool
+	noPreviousIndex       bool
+	esTemplateDrift       bool
+	dsInstanceNotFound    bool
+	dsInstanceState       string
+	hierarchyNotFound     bool
+	hierarchyError        bool
+	queueDeliveryFailed   bool
+	queueUnavailable      bool
+	queueHighPriority     bool
 }
 type testRes struct {
-	w              *httptest.ResponseRecorder
-	datasetAPIMock *mocks.DatasetAPI
+	w                   *httptest.ResponseRecorder
+	datasetAPIMock      *mocks.DatasetAPI
+	outputQueueMock     *mocks.BuildSearch
+	orphanCollectorMock *mocks.OrphanCollector
+	reconcilerMock      *mocks.MissingIndexReconciler
 }
 
 func setupTest(opts testOpts) testRes {
 	if opts.method == "" {
 		opts.method = "GET"
 	}
-	r := httptest.NewRequest(opts.method, opts.url, http.NoBody)
+	var body io.Reader = http.NoBody
+	if opts.body != "" {
+		body = strings.NewReader(opts.body)
+	}
+	r := httptest.NewRequest(opts.method, opts.url, body)
 	w := httptest.NewRecorder()
 
 	if opts.maxResults == 0 {
 		opts.maxResults = defaultMaxResults
 	}
 
-	datasetAPIMock := &mocks.DatasetAPI{InternalServerError: opts.dsInternalServerError, VersionNotFound: opts.dsVersionNotFound, RequireNoAuth: opts.dsRequireNoAuth, RequireAuth: opts.dsRequireAuth}
+	datasetAPIMock := &mocks.DatasetAPI{InternalServerError: opts.dsInternalServerError, VersionNotFound: opts.dsVersionNotFound, DatasetNotFound: opts.dsDatasetNotFound, EditionNotFound: opts.dsEditionNotFound, RequireNoAuth: opts.dsRequireNoAuth, RequireAuth: opts.dsRequireAuth}
+	if opts.dsInstanceNotFound || opts.dsInstanceState != "" {
+		datasetAPIMock.Instances = map[string]dataset.Instance{}
+	}
+	if opts.dsInstanceState != "" {
+		instance := dataset.Instance{}
+		instance.ID = "123"
+		instance.State = opts.dsInstanceState
+		instance.Dimensions = []dataset.VersionDimension{{ID: "aggregate", Name: "aggregate"}}
+		datasetAPIMock.Instances["123"] = instance
+	}
+	hierarchyAPIMock := &mocks.HierarchyAPI{InternalServerError: opts.hierarchyError, Hierarchies: map[string]bool{"123_aggregate": !opts.hierarchyNotFound}}
 
 	// fake the auth wrapper by adding user,caller to r.Context() before ServeHTTP() is called
 	if opts.reqHasAuth {
@@ -72,11 +115,16 @@ func setupTest(opts testOpts) testRes {
 		r.Header.Add("X-Forwarded-Host", "api.example.com")
 		r.Header.Add("X-Forwarded-Path-Prefix", "/v1")
 	}
-	api := routes(host, mux.NewRouter(), &mocks.BuildSearch{ReturnError: opts.searchReturnError}, datasetAPIMock, opts.serviceAuthToken, &mocks.Elasticsearch{InternalServerError: opts.esInternalServerError, IndexNotFound: opts.esIndexNotFound}, opts.maxResults, opts.privateSubnet, nil, opts.enableURLRewriting)
+	orphanCollectorMock := &mocks.OrphanCollector{InternalServerError: opts.orphanCollectorError}
+	reconcilerMock := &mocks.MissingIndexReconciler{InternalServerError: opts.reconcilerError, InProgress: opts.reconcilerInProgress}
+
+	outputQueueMock := &mocks.BuildSearch{ReturnError: opts.searchReturnError, DeliveryFailed: opts.queueDeliveryFailed, Unavailable: opts.queueUnavailable, HighPriority: opts.queueHighPriority}
+
+	api := routes(host, mux.NewRouter(), outputQueueMock, datasetAPIMock, hierarchyAPIMock, opts.serviceAuthToken, &mocks.Elasticsearch{InternalServerError: opts.esInternalServerError, IndexNotFound: opts.esIndexNotFound, Unavailable: opts.esUnavailable, TimedOut: opts.esTimedOut, TotalCapped: opts.esTotalCapped, AliasTargetNotFound: opts.aliasTargetNotFound, LegacyIndex: opts.legacyIndex, NoPreviousIndex: opts.noPreviousIndex, TemplateDrift: opts.esTemplateDrift}, orphanCollectorMock, reconcilerMock, opts.maxResults, opts.privateSubnet, nil, opts.enableURLRewriting)
 
 	api.router.ServeHTTP(w, r)
 
-	return testRes{w: w, datasetAPIMock: datasetAPIMock}
+	return testRes{w: w, datasetAPIMock: datasetAPIMock, outputQueueMock: outputQueueMock, orphanCollectorMock: orphanCollectorMock, reconcilerMock: reconcilerMock}
 }
 
 func TestGetSearchPublishedWithoutAuthReturnsOK(t *testing.T) {
@@ -234,6 +282,33 @@ func TestGetSearchWithAuthReturnsOK(t *testing.T) {
 		So(searchResults.Limit, ShouldEqual, defaultMaxResults)
 		So(searchResults.Offset, ShouldEqual, 0)
 		So(searchResults.TotalCount, ShouldEqual, 22)
+		So(searchResults.TotalCountRelation, ShouldEqual, models.TotalCountExact)
+	})
+
+	Convey("Given more matches than elasticsearch counts by default then return a status 200 with the total as a lower bound", t, func() {
+		testres := setupTest(testOpts{
+			url:           "http://localhost:23100/dimension-search/datasets/123/editions/2017/versions/1/dimensions/aggregate?q=term",
+			esTotalCapped: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusOK)
+
+		searchResults := getSearchResults(testres.w.Body)
+
+		So(searchResults.TotalCount, ShouldEqual, 10000)
+		So(searchResults.TotalCountRelation, ShouldEqual, models.TotalCountLowerBound)
+	})
+
+	Convey("Given more matches than elasticsearch counts by default when an exact total count is requested then return a status 200 with the exact total", t, func() {
+		testres := setupTest(testOpts{
+			url:           "http://localhost:23100/dimension-search/datasets/123/editions/2017/versions/1/dimensions/aggregate?q=term&exact_totalcount=true",
+			esTotalCapped: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusOK)
+
+		searchResults := getSearchResults(testres.w.Body)
+
+		So(searchResults.TotalCount, ShouldEqual, 12345)
+		So(searchResults.TotalCountRelation, ShouldEqual, models.TotalCountExact)
 	})
 }
 
@@ -259,6 +334,24 @@ func TestGetSearchFailureScenarios(t *testing.T) {
 		So(testres.datasetAPIMock.IsAuthenticated, ShouldEqual, false)
 	})
 
+	Convey("Given the dataset was not found via the dataset API return status 404 (not found)", t, func() {
+		testres := setupTest(testOpts{
+			url:               "http://localhost:23100/dimension-search/datasets/123/editions/2017/versions/1/dimensions/aggregate?q=term",
+			dsDatasetNotFound: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusNotFound)
+		So(testres.w.Body.String(), ShouldEqual, errs.ErrDatasetNotFound.Error()+"\n")
+	})
+
+	Convey("Given the edition was not found via the dataset API return status 404 (not found)", t, func() {
+		testres := setupTest(testOpts{
+			url:               "http://localhost:23100/dimension-search/datasets/123/editions/2017/versions/1/dimensions/aggregate?q=term",
+			dsEditionNotFound: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusNotFound)
+		So(testres.w.Body.String(), ShouldEqual, errs.ErrEditionNotFound.Error()+"\n")
+	})
+
 	Convey("Given the limit parameter in request is not a number return status 400 (bad request)", t, func() {
 		testres := setupTest(testOpts{
 			url: "http://localhost:23100/dimension-search/datasets/123/editions/2017/versions/1/dimensions/aggregate?q=term&limit=four",
@@ -275,6 +368,14 @@ func TestGetSearchFailureScenarios(t *testing.T) {
 		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrParsingQueryParameters.Error())
 	})
 
+	Convey("Given the exact_totalcount parameter in request is not a boolean return status 400 (bad request)", t, func() {
+		testres := setupTest(testOpts{
+			url: "http://localhost:23100/dimension-search/datasets/123/editions/2017/versions/1/dimensions/aggregate?q=term&exact_totalcount=always",
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusBadRequest)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrInvalidExactTotalCount.Error())
+	})
+
 	Convey("Given the query parameter, q does not exist in request return status 400 (bad request)", t, func() {
 		testres := setupTest(testOpts{
 			url: "http://localhost:23100/dimension-search/datasets/123/editions/2017/versions/1/dimensions/aggregate",
@@ -300,13 +401,33 @@ func TestGetSearchFailureScenarios(t *testing.T) {
 		So(testres.w.Body.String(), ShouldEqual, "internal server error\n")
 	})
 
-	Convey("Given the search index does not exist but the version resource does then return status 500 (internal server error)", t, func() {
+	Convey("Given the search index does not exist but the version resource does then return status 404 (not found)", t, func() {
 		testres := setupTest(testOpts{
 			url:             "http://localhost:23100/dimension-search/datasets/123/editions/2017/versions/1/dimensions/aggregate?q=term",
 			esIndexNotFound: true,
 		})
-		So(testres.w.Code, ShouldEqual, http.StatusInternalServerError)
-		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrInternalServer.Error())
+		So(testres.w.Code, ShouldEqual, http.StatusNotFound)
+		So(testres.w.Body.String(), ShouldEqual, "dimension is not searchable\n")
+		So(testres.w.Header().Get("Retry-After"), ShouldBeEmpty)
+	})
+
+	Convey("Given elasticsearch does not respond in time return status 504 (gateway timeout)", t, func() {
+		testres := setupTest(testOpts{
+			url:        "http://localhost:23100/dimension-search/datasets/123/editions/2017/versions/1/dimensions/aggregate?q=term",
+			esTimedOut: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusGatewayTimeout)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrElasticsearchTimeout.Error())
+	})
+
+	Convey("Given the elasticsearch circuit breaker is open return a status 503 (service unavailable) with a retry after", t, func() {
+		testres := setupTest(testOpts{
+			url:           "http://localhost:23100/dimension-search/datasets/123/editions/2017/versions/1/dimensions/aggregate?q=term",
+			esUnavailable: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrElasticsearchUnavailable.Error())
+		So(testres.w.Header().Get("Retry-After"), ShouldEqual, "25")
 	})
 }
 
@@ -460,6 +581,217 @@ func TestFailToCreateSearchIndex(t *testing.T) {
 		So(testres.w.Code, ShouldEqual, http.StatusInternalServerError)
 		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrInternalServer.Error())
 	})
+
+	Convey("Given kafka does not confirm delivery of the message return a status 503 (service unavailable) without a retry after", t, func() {
+		testres := setupTest(testOpts{
+			method:              "PUT",
+			url:                 "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate",
+			queueDeliveryFailed: true,
+			reqHasAuth:          true,
+			privateSubnet:       true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrQueueDeliveryFailed.Error())
+		So(testres.w.Header().Get("Retry-After"), ShouldBeEmpty)
+	})
+
+	Convey("Given the kafka producer is not accepting messages return a status 503 (service unavailable) with a retry after", t, func() {
+		testres := setupTest(testOpts{
+			method:           "PUT",
+			url:              "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate",
+			queueUnavailable: true,
+			reqHasAuth:       true,
+			privateSubnet:    true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrQueueUnavailable.Error())
+		So(testres.w.Header().Get("Retry-After"), ShouldEqual, "5")
+	})
+
+	Convey("Given the instance does not exist return a status 404 (not found) without queuing the search index", t, func() {
+		testres := setupTest(testOpts{
+			method:             "PUT",
+			url:                "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate",
+			dsInstanceNotFound: true,
+			reqHasAuth:         true,
+			privateSubnet:      true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusNotFound)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrInstanceNotFound.Error())
+		So(testres.outputQueueMock.Queued, ShouldBeEmpty)
+	})
+
+	Convey("Given the dimension does not exist on the instance return a status 404 (not found) without queuing the search index", t, func() {
+		testres := setupTest(testOpts{
+			method:        "PUT",
+			url:           "http://localhost:23100/dimension-search/instances/123/dimensions/agregate",
+			reqHasAuth:    true,
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusNotFound)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrDimensionNotFound.Error())
+		So(testres.outputQueueMock.Queued, ShouldBeEmpty)
+	})
+
+	Convey("Given the instance has been detached return a status 409 (conflict) without queuing the search index", t, func() {
+		testres := setupTest(testOpts{
+			method:          "PUT",
+			url:             "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate",
+			dsInstanceState: "detached",
+			reqHasAuth:      true,
+			privateSubnet:   true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusConflict)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrInstanceNotIndexable.Error())
+		So(testres.outputQueueMock.Queued, ShouldBeEmpty)
+	})
+
+	Convey("Given the dimension has no hierarchy return a status 409 (conflict) without queuing the search index", t, func() {
+		testres := setupTest(testOpts{
+			method:            "PUT",
+			url:               "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate",
+			hierarchyNotFound: true,
+			reqHasAuth:        true,
+			privateSubnet:     true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusConflict)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrDimensionNotHierarchical.Error())
+		So(testres.outputQueueMock.Queued, ShouldBeEmpty)
+	})
+
+	Convey("Given the dataset API is unavailable return a status 500 (internal server error) without queuing the search index", t, func() {
+		testres := setupTest(testOpts{
+			method:                "PUT",
+			url:                   "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate",
+			dsInternalServerError: true,
+			reqHasAuth:            true,
+			privateSubnet:         true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusInternalServerError)
+		So(testres.outputQueueMock.Queued, ShouldBeEmpty)
+	})
+}
+
+func TestCreateSearchIndexWithoutHierarchyCheckReturnsOK(t *testing.T) {
+	Convey("Given the hierarchy API is unavailable the search index is still queued once the dataset API confirms the dimension", t, func() {
+		testres := setupTest(testOpts{
+			method:         "PUT",
+			url:            "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate",
+			hierarchyError: true,
+			reqHasAuth:     true,
+			privateSubnet:  true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusOK)
+		So(testres.outputQueueMock.Queued, ShouldHaveLength, 1)
+	})
+}
+
+func TestCreateSearchIndexCoalescesRepeatedRequests(t *testing.T) {
+	Convey("Given a request to create a search index has just been queued", t, func() {
+		outputQueueMock := &mocks.BuildSearch{}
+		api := routes(host, mux.NewRouter(), searchoutputqueue.NewDeduplicator(outputQueueMock, time.Minute), &mocks.DatasetAPI{}, &mocks.HierarchyAPI{Hierarchies: map[string]bool{"123_aggregate": true}}, "1234", &mocks.Elasticsearch{}, &mocks.OrphanCollector{}, &mocks.MissingIndexReconciler{}, defaultMaxResults, true, nil, false)
+
+		createSearchIndex := func(url string) models.SearchIndexBuild {
+			r := httptest.NewRequest("PUT", url, http.NoBody)
+			r = r.WithContext(request.SetCaller(r.Context(), "APIAmWhoAPIAm"))
+			w := httptest.NewRecorder()
+			api.router.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusOK)
+
+			var build models.SearchIndexBuild
+			So(json.Unmarshal(w.Body.Bytes(), &build), ShouldBeNil)
+			return build
+		}
+
+		build := createSearchIndex("http://localhost:23100/dimension-search/instances/123/dimensions/aggregate")
+		So(build, ShouldResemble, models.SearchIndexBuild{Dimension: "aggregate", InstanceID: "123", Priority: "normal"})
+
+		Convey("When the same request is made again return a status 200 (ok) saying it was coalesced", func() {
+			build = createSearchIndex("http://localhost:23100/dimension-search/instances/123/dimensions/aggregate")
+			So(build.Coalesced, ShouldBeTrue)
+			So(outputQueueMock.Queued, ShouldHaveLength, 1)
+		})
+
+		Convey("When the same request is forced return a status 200 (ok) and queue it again", func() {
+			build = createSearchIndex("http://localhost:23100/dimension-search/instances/123/dimensions/aggregate?force=true")
+			So(build.Coalesced, ShouldBeFalse)
+			So(outputQueueMock.Queued, ShouldHaveLength, 2)
+		})
+	})
+}
+
+func TestGetSearchWhileSearchIndexIsBuilding(t *testing.T) {
+	Convey("Given the dataset API reports the import of a version is building the search index of a dimension which is not yet searchable", t, func() {
+		datasetAPI := &mocks.DatasetAPI{BuildSearchIndexTasks: map[string]string{"aggregate": "submitted", "geography": "completed"}}
+
+		// the web instance has no private endpoints and queues no builds of its own
+		api := routes(host, mux.NewRouter(), &mocks.BuildSearch{}, datasetAPI, &mocks.HierarchyAPI{}, "", &mocks.Elasticsearch{IndexNotFound: true}, &mocks.OrphanCollector{}, &mocks.MissingIndexReconciler{}, defaultMaxResults, false, nil, false)
+
+		search := func(dimension string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("GET", "http://localhost:23100/dimension-search/datasets/123/editions/2017/versions/1/dimensions/"+dimension+"?q=term", http.NoBody)
+			w := httptest.NewRecorder()
+			api.router.ServeHTTP(w, r)
+			return w
+		}
+
+		Convey("When the dimension is searched on the web instance return a status 503 (service unavailable) with a retry after", func() {
+			w := search("aggregate")
+			So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(w.Body.String(), ShouldContainSubstring, errs.ErrSearchIndexBuilding.Error())
+			So(w.Header().Get("Retry-After"), ShouldEqual, "5")
+		})
+
+		Convey("When a dimension whose search index build has completed is searched return a status 404 (not found)", func() {
+			w := search("geography")
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(w.Body.String(), ShouldContainSubstring, errs.ErrDimensionNotSearchable.Error())
+		})
+
+		Convey("When a dimension with no search index build is searched return a status 404 (not found)", func() {
+			w := search("sex")
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(w.Body.String(), ShouldContainSubstring, errs.ErrDimensionNotSearchable.Error())
+		})
+	})
+}
+
+func TestCreateSearchIndexWithPriority(t *testing.T) {
+	Convey("Given a request to create a search index with high priority return a status 200 (ok) and queue it with high priority", t, func() {
+		testres := setupTest(testOpts{
+			method:            "PUT",
+			url:               "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate?priority=high",
+			reqHasAuth:        true,
+			privateSubnet:     true,
+			queueHighPriority: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusOK)
+		So(testres.outputQueueMock.Queued, ShouldResemble, []searchoutputqueue.Search{{InstanceID: "123", Dimension: "aggregate", Priority: searchoutputqueue.PriorityHigh}})
+		So(testres.w.Body.String(), ShouldContainSubstring, `"priority":"high"`)
+	})
+
+	Convey("Given a request to create a search index with high priority but no priority topic return a status 200 (ok) reporting it was queued with normal priority", t, func() {
+		testres := setupTest(testOpts{
+			method:        "PUT",
+			url:           "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate?priority=high",
+			reqHasAuth:    true,
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusOK)
+		So(testres.outputQueueMock.Queued, ShouldResemble, []searchoutputqueue.Search{{InstanceID: "123", Dimension: "aggregate"}})
+		So(testres.w.Body.String(), ShouldContainSubstring, `"priority":"normal"`)
+	})
+
+	Convey("Given a request to create a search index with an unknown priority return a status 400 (bad request)", t, func() {
+		testres := setupTest(testOpts{
+			method:        "PUT",
+			url:           "http://localhost:23100/dimension-search/instances/123/dimensions/aggregate?priority=urgent",
+			reqHasAuth:    true,
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusBadRequest)
+		So(testres.w.Body.String(), ShouldContainSubstring, errs.ErrInvalidPriority.Error())
+		So(testres.outputQueueMock.Queued, ShouldBeEmpty)
+	})
 }
 
 func TestDeleteSearchIndexReturnsOK(t *testing.T) {
@@ -589,6 +921,41 @@ func TestCreateSearchIndexEndpointInWebReturnsNotFound(t *testing.T) {
 	})
 }
 
+func TestSetErrorCode(t *testing.T) {
+	Convey("Given an error of the API wrapped by another error", t, func() {
+		err := fmt.Errorf("failed to queue search index: %w", errs.ErrQueueUnavailable)
+
+		Convey("Then the response has the status and message of the error of the API", func() {
+			w := httptest.NewRecorder()
+			setErrorCode(context.Background(), w, err)
+			So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(w.Body.String(), ShouldEqual, errs.ErrQueueUnavailable.Error()+"\n")
+			So(w.Header().Get("Retry-After"), ShouldEqual, "5")
+		})
+	})
+
+	Convey("Given an internal error of the API", t, func() {
+		Convey("Then the response is a 500 without the detail of the error", func() {
+			w := httptest.NewRecorder()
+			setErrorCode(context.Background(), w, errs.ErrUnmarshallingJSON)
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			So(w.Body.String(), ShouldEqual, "internal server error\n")
+		})
+	})
+
+	Convey("Given an error the dataset API client returns for a response other than a 404", t, func() {
+		resp := &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader("bad gateway"))}
+		err := (&SearchAPI{}).versionError(context.Background(), dataset.NewDatasetAPIResponse(resp, "/datasets/123/editions/2017/versions/1"), "", "123", "2017")
+
+		Convey("Then the response is a 500 without the detail of the error", func() {
+			w := httptest.NewRecorder()
+			setErrorCode(context.Background(), w, err)
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			So(w.Body.String(), ShouldEqual, "internal server error\n")
+		})
+	})
+}
+
 func getSearchResults(body *bytes.Buffer) *models.SearchResults {
 	jsonBody, err := io.ReadAll(body)
 	if err != nil {
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+func (api *SearchAPI) checkIndexTemplate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	log.Info(ctx, "checkIndexTemplate endpoint: attempting to check index template")
+
+	report, err := api.elasticsearch.CheckTemplate(ctx)
+	if err != nil {
+		log.Error(ctx, "checkIndexTemplate endpoint: failed to check index template", err)
+		setErrorCode(ctx, w, err)
+		return
+	}
+
+	writeIndexTemplateReport(w, r, report)
+
+	log.Info(ctx, "checkIndexTemplate endpoint: index template checked", log.Data{"drift": len(report.Drift)})
+}
+
+func (api *SearchAPI) installIndexTemplate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	log.Info(ctx, "installIndexTemplate endpoint: attempting to install index template")
+
+	report, err := api.elasticsearch.InstallTemplate(ctx)
+	if err != nil {
+		log.Error(ctx, "installIndexTemplate endpoint: failed to install index template", err)
+		setErrorCode(ctx, w, err)
+		return
+	}
+
+	writeIndexTemplateReport(w, r, report)
+
+	log.Info(ctx, "installIndexTemplate endpoint: index template installed", log.Data{"updated": report.Updated, "drift": len(report.Drift)})
+}
+
+func writeIndexTemplateReport(w http.ResponseWriter, r *http.Request, report *models.IndexTemplateReport) {
+	ctx := r.Context()
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		log.Error(ctx, "failed to marshal index template report into bytes", err)
+		setErrorCode(ctx, w, errs.ErrInternalServer)
+		return
+	}
+
+	setJSONContentType(w)
+	if _, err = w.Write(b); err != nil {
+		log.Error(ctx, "error writing response", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCheckIndexTemplateReturnsOK(t *testing.T) {
+	Convey("Given the index template has drifted return a status 200 (ok) with the drift", t, func() {
+		testres := setupTest(testOpts{
+			url:             "http://localhost:23100/dimension-search/index-template",
+			reqHasAuth:      true,
+			privateSubnet:   true,
+			esTemplateDrift: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusOK)
+
+		report := &models.IndexTemplateReport{}
+		So(json.Unmarshal(testres.w.Body.Bytes(), report), ShouldBeNil)
+		So(report.Updated, ShouldBeFalse)
+		So(report.Drift, ShouldResemble, []models.MappingDrift{{Field: "label.keyword", Expected: "keyword normalizer=sortable"}})
+	})
+}
+
+func TestInstallIndexTemplateReturnsOK(t *testing.T) {
+	Convey("Given the index template has drifted return a status 200 (ok) once it is installed", t, func() {
+		testres := setupTest(testOpts{
+			method:          "PUT",
+			url:             "http://localhost:23100/dimension-search/index-template",
+			reqHasAuth:      true,
+			privateSubnet:   true,
+			esTemplateDrift: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusOK)
+
+		report := &models.IndexTemplateReport{}
+		So(json.Unmarshal(testres.w.Body.Bytes(), report), ShouldBeNil)
+		So(report.Updated, ShouldBeTrue)
+		So(report.Drift, ShouldBeEmpty)
+	})
+}
+
+func TestFailToManageIndexTemplate(t *testing.T) {
+	Convey("Given a request to check the index template but no auth header is set return a status 401 (unauthorized)", t, func() {
+		testres := setupTest(testOpts{
+			url:           "http://localhost:23100/dimension-search/index-template",
+			privateSubnet: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusUnauthorized)
+	})
+
+	Convey("Given private endpoints are disabled return a status 404 (not found)", t, func() {
+		testres := setupTest(testOpts{
+			method:     "PUT",
+			url:        "http://localhost:23100/dimension-search/index-template",
+			reqHasAuth: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("Given elasticsearch is unavailable return a status 500 (internal server error)", t, func() {
+		testres := setupTest(testOpts{
+			method:                "PUT",
+			url:                   "http://localhost:23100/dimension-search/index-template",
+			reqHasAuth:            true,
+			privateSubnet:         true,
+			esInternalServerError: true,
+		})
+		So(testres.w.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+}
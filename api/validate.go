@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+const (
+	stateDetached = "detached"
+	stateFailed   = "failed"
+)
+
+// validateInstanceDimension checks the instance exists, can be indexed and has the dimension, so that
+// a search index is not queued to be built for a typo. When a hierarchy API client is available the
+// dimension must also have a hierarchy, unless the hierarchy API cannot be reached.
+func (api *SearchAPI) validateInstanceDimension(ctx context.Context, instanceID, dimension string) error {
+	logData := log.Data{"instance_id": instanceID, "dimension": dimension}
+
+	instance, err := api.datasetAPIClient.GetInstance(ctx, "", api.serviceAuthToken, "", instanceID)
+	if err != nil {
+		if errs.IsNotFound(err) {
+			return errs.ErrInstanceNotFound
+		}
+		log.Error(ctx, "failed to get instance from dataset API", err, logData)
+		return err
+	}
+
+	if instance.State == stateDetached || instance.State == stateFailed {
+		return errs.ErrInstanceNotIndexable
+	}
+
+	found := false
+	for i := range instance.Dimensions {
+		if instance.Dimensions[i].Name == dimension || instance.Dimensions[i].ID == dimension {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return errs.ErrDimensionNotFound
+	}
+
+	if api.hierarchyAPIClient == nil {
+		return nil
+	}
+
+	if _, err = api.hierarchyAPIClient.GetRoot(ctx, instanceID, dimension); err != nil {
+		if errs.IsNotFound(err) {
+			return errs.ErrDimensionNotHierarchical
+		}
+		// the hierarchy check is best effort, the dataset API has already confirmed the dimension exists
+		log.Error(ctx, "unable to check dimension has a hierarchy, continuing", err, logData)
+	}
+
+	return nil
+}
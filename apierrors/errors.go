@@ -1,31 +1,82 @@
 package apierrors
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Error is an error of Search API, with the HTTP status it is returned with and a code identifying it
+type Error struct {
+	Status  int
+	Code    string
+	message string
+}
+
+// New returns an error returned with status and identified by code
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, message: message}
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+// RetryAfterError is an error of Search API for a request which can be retried once After has passed
+type RetryAfterError struct {
+	Err   *Error
+	After time.Duration
+}
+
+// WithRetryAfter returns err for a request which can be retried once after has passed
+func WithRetryAfter(err *Error, after time.Duration) *RetryAfterError {
+	return &RetryAfterError{Err: err, After: after}
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// IsNotFound reports whether err is, or wraps, an error of an upstream API client for a 404 response
+func IsNotFound(err error) bool {
+	var upstreamErr interface{ Code() int }
+	return errors.As(err, &upstreamErr) && upstreamErr.Code() == http.StatusNotFound
+}
 
 // A list of error messages for Search API
 var (
-	ErrDatasetNotFound        = errors.New("dataset not found")
-	ErrDeleteIndexNotFound    = errors.New("search index not found")
-	ErrEditionNotFound        = errors.New("edition not found")
-	ErrEmptySearchTerm        = errors.New("empty search term")
-	ErrIndexNotFound          = errors.New("search index not found")
-	ErrInternalServer         = errors.New("internal server error")
-	ErrMarshallingQuery       = errors.New("failed to marshal query to bytes for request body to send to elastic")
-	ErrParsingQueryParameters = errors.New("failed to parse query parameters, values must be an integer")
-	ErrUnauthenticatedRequest = errors.New("unauthenticated request")
-	ErrUnmarshallingJSON      = errors.New("failed to parse json body")
-	ErrUnexpectedStatusCode   = errors.New("unexpected status code from elastic api")
-	ErrVersionNotFound        = errors.New("version not found")
-
-	NotFoundMap = map[error]bool{
-		ErrDatasetNotFound:     true,
-		ErrDeleteIndexNotFound: true,
-		ErrEditionNotFound:     true,
-		ErrVersionNotFound:     true,
-	}
-
-	BadRequestMap = map[error]bool{
-		ErrEmptySearchTerm:        true,
-		ErrParsingQueryParameters: true,
-	}
+	ErrAliasTargetNotFound      = New(http.StatusNotFound, "alias_target_not_found", "index to point alias at not found")
+	ErrDatasetNotFound          = New(http.StatusNotFound, "dataset_not_found", "dataset not found")
+	ErrDeleteIndexNotFound      = New(http.StatusNotFound, "search_index_not_found", "search index not found")
+	ErrDimensionNotFound        = New(http.StatusNotFound, "dimension_not_found", "dimension not found on instance")
+	ErrDimensionNotHierarchical = New(http.StatusConflict, "dimension_not_hierarchical", "dimension has no hierarchy to index")
+	ErrDimensionNotSearchable   = New(http.StatusNotFound, "dimension_not_searchable", "dimension is not searchable")
+	ErrEditionNotFound          = New(http.StatusNotFound, "edition_not_found", "edition not found")
+	ErrElasticsearchTimeout     = New(http.StatusGatewayTimeout, "elasticsearch_timeout", "elasticsearch did not respond in time")
+	ErrElasticsearchUnavailable = New(http.StatusServiceUnavailable, "elasticsearch_unavailable", "elasticsearch is unavailable at the moment")
+	ErrEmptySearchTerm          = New(http.StatusBadRequest, "empty_search_term", "empty search term")
+	ErrInstanceNotFound         = New(http.StatusNotFound, "instance_not_found", "instance not found")
+	ErrInstanceNotIndexable     = New(http.StatusConflict, "instance_not_indexable", "instance has failed or been detached so cannot be indexed")
+	ErrInternalServer           = New(http.StatusInternalServerError, "internal_server_error", "internal server error")
+	ErrInvalidRequestBody       = New(http.StatusBadRequest, "invalid_request_body", "failed to parse request body")
+	ErrInvalidAliasTarget       = New(http.StatusBadRequest, "invalid_alias_target", "index to point alias at must be a version of the alias")
+	ErrInvalidExactTotalCount   = New(http.StatusBadRequest, "invalid_exact_totalcount", "invalid exact_totalcount, must be true or false")
+	ErrInvalidPriority          = New(http.StatusBadRequest, "invalid_priority", "invalid priority, must be normal or high")
+	ErrLegacyIndexExists        = New(http.StatusConflict, "legacy_index_exists", "an index named as the alias exists and would be deleted, set replace_legacy_index to swap the alias anyway")
+	ErrMarshallingQuery         = New(http.StatusInternalServerError, "marshalling_query", "failed to marshal query to bytes for request body to send to elastic")
+	ErrNoPreviousIndex          = New(http.StatusConflict, "no_previous_index", "no previous index to roll alias back to")
+	ErrParsingQueryParameters   = New(http.StatusBadRequest, "invalid_query_parameters", "failed to parse query parameters, values must be an integer")
+	ErrQueueDeliveryFailed      = New(http.StatusServiceUnavailable, "queue_delivery_failed", "failed to confirm delivery of search index build request")
+	ErrQueueUnavailable         = New(http.StatusServiceUnavailable, "queue_unavailable", "search index build requests cannot be queued at the moment")
+	ErrReconciliationInProgress = New(http.StatusConflict, "reconciliation_in_progress", "a reconciliation is already in progress")
+	ErrSearchIndexBuilding      = New(http.StatusServiceUnavailable, "search_index_building", "search index is being built, try again shortly")
+	ErrTemplateNotSupported     = New(http.StatusConflict, "index_template_not_supported", "index template cannot be installed on elasticsearch 6, which requires a document type")
+	ErrUnauthenticatedRequest   = New(http.StatusUnauthorized, "unauthenticated_request", "unauthenticated request")
+	ErrUnmarshallingJSON        = New(http.StatusInternalServerError, "unmarshalling_json", "failed to parse json body")
+	ErrUnexpectedStatusCode     = New(http.StatusInternalServerError, "unexpected_status_code", "unexpected status code from elastic api")
+	ErrVersionNotFound          = New(http.StatusNotFound, "version_not_found", "version not found")
 )
@@ -4,25 +4,55 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/ONSdigital/dp-dimension-search-api/models"
 	"github.com/kelseyhightower/envconfig"
 )
 
+// RankingBoosts is the ranking boost for each dimension with one, keyed by dimension name
+type RankingBoosts map[string]models.RankingBoost
+
+// Decode decodes the ranking boosts from json, e.g. {"geography":{"number_of_children":1,"depth":2}}
+func (boosts *RankingBoosts) Decode(value string) error {
+	return json.Unmarshal([]byte(value), (*map[string]models.RankingBoost)(boosts))
+}
+
 // Config is the filing resource handler config
 type Config struct {
 	AuthAPIURL                 string        `envconfig:"ZEBEDEE_URL"`
 	AwsRegion                  string        `envconfig:"AWS_REGION"`
 	AwsService                 string        `envconfig:"AWS_SERVICE"`
 	BindAddr                   string        `envconfig:"BIND_ADDR"`
+	BreakerCooldown            time.Duration `envconfig:"ELASTIC_BREAKER_COOLDOWN"`
+	BreakerErrorRate           float64       `envconfig:"ELASTIC_BREAKER_ERROR_RATE"`
+	BreakerFailures            int           `envconfig:"ELASTIC_BREAKER_FAILURES"`
+	BreakerWindow              int           `envconfig:"ELASTIC_BREAKER_WINDOW"`
 	Brokers                    []string      `envconfig:"KAFKA_ADDR"                 json:"-"`
+	BuildDedupWindow           time.Duration `envconfig:"BUILD_DEDUP_WINDOW"`
 	DatasetAPIURL              string        `envconfig:"DATASET_API_URL"`
 	ElasticSearchAPIURL        string        `envconfig:"ELASTIC_SEARCH_URL"         json:"-"`
+	ElasticSearchBackend       string        `envconfig:"ELASTIC_SEARCH_BACKEND"`
+	ElasticSearchReadMode      string        `envconfig:"ELASTIC_SEARCH_READ_MODE"`
+	ElasticSearchSecondaryURLs []string      `envconfig:"ELASTIC_SECONDARY_URLS"     json:"-"`
+	ElasticSearchTimeout       time.Duration `envconfig:"ELASTIC_SEARCH_TIMEOUT"`
 	GracefulShutdownTimeout    time.Duration `envconfig:"GRACEFUL_SHUTDOWN_TIMEOUT"`
 	HasPrivateEndpoints        bool          `envconfig:"ENABLE_PRIVATE_ENDPOINTS"`
 	HealthCheckInterval        time.Duration `envconfig:"HEALTHCHECK_INTERVAL"`
 	HealthCheckCriticalTimeout time.Duration `envconfig:"HEALTHCHECK_CRITICAL_TIMEOUT"`
+	HierarchyAPIURL            string        `envconfig:"HIERARCHY_API_URL"`
 	HierarchyBuiltTopic        string        `envconfig:"HIERARCHY_BUILT_TOPIC"`
+	HierarchyPriorityTopic     string        `envconfig:"HIERARCHY_BUILT_PRIORITY_TOPIC"`
+	InstallIndexTemplate       bool          `envconfig:"INSTALL_INDEX_TEMPLATE"`
+	InstanceRemovedConsumer    bool          `envconfig:"ENABLE_INSTANCE_REMOVED_CONSUMER"`
+	InstanceRemovedGroup       string        `envconfig:"INSTANCE_REMOVED_GROUP"`
+	InstanceRemovedTopic       string        `envconfig:"INSTANCE_REMOVED_TOPIC"`
+	KafkaConfirmDelivery       bool          `envconfig:"KAFKA_CONFIRM_DELIVERY"`
+	KafkaDeliveryTimeout       time.Duration `envconfig:"KAFKA_DELIVERY_TIMEOUT"`
+	KafkaEnqueueTimeout        time.Duration `envconfig:"KAFKA_ENQUEUE_TIMEOUT"`
 	KafkaMaxBytes              int           `envconfig:"KAFKA_MAX_BYTES"`
 	KafkaVersion               string        `envconfig:"KAFKA_VERSION"`
+	KafkaSASLMechanism         string        `envconfig:"KAFKA_SASL_MECHANISM"`
+	KafkaSASLUsername          string        `envconfig:"KAFKA_SASL_USERNAME"`
+	KafkaSASLPassword          string        `envconfig:"KAFKA_SASL_PASSWORD"        json:"-"`
 	KafkaSecProtocol           string        `envconfig:"KAFKA_SEC_PROTO"`
 	KafkaSecCACerts            string        `envconfig:"KAFKA_SEC_CA_CERTS"`
 	KafkaSecClientCert         string        `envconfig:"KAFKA_SEC_CLIENT_CERT"`
@@ -30,10 +60,20 @@ type Config struct {
 	KafkaSecSkipVerify         bool          `envconfig:"KAFKA_SEC_SKIP_VERIFY"`
 	MaxRetries                 int           `envconfig:"REQUEST_MAX_RETRIES"`
 	MaxSearchResultsOffset     int           `envconfig:"MAX_SEARCH_RESULTS_OFFSET"`
+	MissingIndexRebuildRate    int           `envconfig:"MISSING_INDEX_REBUILD_RATE"`
+	OrphanedIndexGCDryRun      bool          `envconfig:"ORPHANED_INDEX_GC_DRY_RUN"`
+	OrphanedIndexGCInterval    time.Duration `envconfig:"ORPHANED_INDEX_GC_INTERVAL"`
+	OutboxPath                 string        `envconfig:"OUTBOX_PATH"`
+	OutputQueueBackend         string        `envconfig:"OUTPUT_QUEUE_BACKEND"`
+	OutputQueueFilePath        string        `envconfig:"OUTPUT_QUEUE_FILE_PATH"`
+	OutputQueueWebhookURL      string        `envconfig:"OUTPUT_QUEUE_WEBHOOK_URL"`
+	OutboxRetryInterval        time.Duration `envconfig:"OUTBOX_RETRY_INTERVAL"`
 	OTExporterOTLPEndpoint     string        `envconfig:"OTEL_EXPORTER_OTLP_ENDPOINT"`
 	OTServiceName              string        `envconfig:"OTEL_SERVICE_NAME"`
 	OTBatchTimeout             time.Duration `envconfig:"OTEL_BATCH_TIMEOUT"`
+	RankingBoosts              RankingBoosts `envconfig:"RANKING_BOOSTS"`
 	SearchAPIURL               string        `envconfig:"SEARCH_API_URL"`
+	SearchFixturesPath         string        `envconfig:"SEARCH_FIXTURES_PATH"`
 	ServiceAuthToken           string        `envconfig:"SERVICE_AUTH_TOKEN"         json:"-"`
 	SignElasticsearchRequests  bool          `envconfig:"SIGN_ELASTICSEARCH_REQUESTS"`
 	EnableURLRewriting         bool          `envconfig:"ENABLE_URL_REWRITING"`
@@ -52,22 +92,53 @@ func Get() (*Config, error) {
 		AwsRegion:                  "eu-west-1",
 		AwsService:                 "es",
 		BindAddr:                   ":23100",
+		BreakerCooldown:            30 * time.Second,
+		BreakerErrorRate:           0.5,
+		BreakerFailures:            5,
+		BreakerWindow:              20,
 		Brokers:                    []string{"localhost:9092", "localhost:9093", "localhost:9094"},
+		BuildDedupWindow:           0,
 		DatasetAPIURL:              "http://localhost:22000",
 		ElasticSearchAPIURL:        "http://localhost:10200",
+		ElasticSearchBackend:       "elasticsearch",
+		ElasticSearchReadMode:      "failover",
+		ElasticSearchSecondaryURLs: []string{},
+		ElasticSearchTimeout:       10 * time.Second,
 		GracefulShutdownTimeout:    5 * time.Second,
 		HasPrivateEndpoints:        true,
 		HealthCheckInterval:        30 * time.Second,
 		HealthCheckCriticalTimeout: 90 * time.Second,
+		HierarchyAPIURL:            "http://localhost:22600",
 		HierarchyBuiltTopic:        "hierarchy-built",
+		HierarchyPriorityTopic:     "",
+		InstallIndexTemplate:       true,
+		InstanceRemovedConsumer:    false,
+		InstanceRemovedGroup:       "dp-dimension-search-api",
+		InstanceRemovedTopic:       "instance-removed",
+		KafkaConfirmDelivery:       false,
+		KafkaDeliveryTimeout:       5 * time.Second,
+		KafkaEnqueueTimeout:        2 * time.Second,
 		KafkaMaxBytes:              2000000,
 		KafkaVersion:               "1.0.2",
+		KafkaSASLMechanism:         "",
+		KafkaSASLUsername:          "",
+		KafkaSASLPassword:          "",
 		MaxRetries:                 3,
 		MaxSearchResultsOffset:     1000,
+		MissingIndexRebuildRate:    60,
+		OrphanedIndexGCDryRun:      true,
+		OrphanedIndexGCInterval:    0,
+		OutboxPath:                 "",
+		OutboxRetryInterval:        time.Second,
+		OutputQueueBackend:         "kafka",
+		OutputQueueFilePath:        "search-outputs.jsonl",
+		OutputQueueWebhookURL:      "",
 		OTExporterOTLPEndpoint:     "localhost:4317",
 		OTServiceName:              "dp-dimension-search-api",
 		OTBatchTimeout:             5 * time.Second,
+		RankingBoosts:              RankingBoosts{},
 		SearchAPIURL:               "http://localhost:23100",
+		SearchFixturesPath:         "search-fixtures",
 		ServiceAuthToken:           "a507f722-f25a-4889-9653-23a2655b925c",
 		SignElasticsearchRequests:  false,
 		EnableURLRewriting:         false,
@@ -24,22 +24,80 @@ func TestSpec(t *testing.T) {
 				So(cfg.AwsRegion, ShouldEqual, "eu-west-1")
 				So(cfg.AwsService, ShouldEqual, "es")
 				So(cfg.BindAddr, ShouldEqual, ":23100")
+				So(cfg.BreakerCooldown, ShouldEqual, 30*time.Second)
+				So(cfg.BreakerErrorRate, ShouldEqual, 0.5)
+				So(cfg.BreakerFailures, ShouldEqual, 5)
+				So(cfg.BreakerWindow, ShouldEqual, 20)
 				So(cfg.Brokers, ShouldResemble, []string{"localhost:9092", "localhost:9093", "localhost:9094"})
+				So(cfg.BuildDedupWindow, ShouldEqual, 0)
 				So(cfg.DatasetAPIURL, ShouldEqual, "http://localhost:22000")
 				So(cfg.ElasticSearchAPIURL, ShouldEqual, "http://localhost:10200")
+				So(cfg.ElasticSearchBackend, ShouldEqual, "elasticsearch")
+				So(cfg.ElasticSearchReadMode, ShouldEqual, "failover")
+				So(cfg.ElasticSearchSecondaryURLs, ShouldBeEmpty)
+				So(cfg.ElasticSearchTimeout, ShouldEqual, 10*time.Second)
 				So(cfg.GracefulShutdownTimeout, ShouldEqual, 5*time.Second)
 				So(cfg.HealthCheckInterval, ShouldEqual, 30*time.Second)
 				So(cfg.HealthCheckCriticalTimeout, ShouldEqual, 90*time.Second)
+				So(cfg.HierarchyAPIURL, ShouldEqual, "http://localhost:22600")
+				So(cfg.InstallIndexTemplate, ShouldBeTrue)
+				So(cfg.InstanceRemovedConsumer, ShouldBeFalse)
+				So(cfg.InstanceRemovedGroup, ShouldEqual, "dp-dimension-search-api")
+				So(cfg.InstanceRemovedTopic, ShouldEqual, "instance-removed")
+				So(cfg.KafkaConfirmDelivery, ShouldBeFalse)
+				So(cfg.KafkaDeliveryTimeout, ShouldEqual, 5*time.Second)
+				So(cfg.KafkaEnqueueTimeout, ShouldEqual, 2*time.Second)
 				So(cfg.HierarchyBuiltTopic, ShouldEqual, "hierarchy-built")
+				So(cfg.HierarchyPriorityTopic, ShouldEqual, "")
 				So(cfg.KafkaMaxBytes, ShouldEqual, 2000000)
 				So(cfg.MaxRetries, ShouldEqual, 3)
 				So(cfg.KafkaVersion, ShouldEqual, "1.0.2")
 				So(cfg.KafkaSecProtocol, ShouldEqual, "")
+				So(cfg.KafkaSASLMechanism, ShouldEqual, "")
+				So(cfg.KafkaSASLUsername, ShouldEqual, "")
+				So(cfg.KafkaSASLPassword, ShouldEqual, "")
 				So(cfg.MaxSearchResultsOffset, ShouldEqual, 1000)
+				So(cfg.MissingIndexRebuildRate, ShouldEqual, 60)
+				So(cfg.OrphanedIndexGCDryRun, ShouldBeTrue)
+				So(cfg.OrphanedIndexGCInterval, ShouldEqual, 0)
+				So(cfg.OutboxPath, ShouldEqual, "")
+				So(cfg.OutboxRetryInterval, ShouldEqual, time.Second)
+				So(cfg.OutputQueueBackend, ShouldEqual, "kafka")
+				So(cfg.OutputQueueFilePath, ShouldEqual, "search-outputs.jsonl")
+				So(cfg.OutputQueueWebhookURL, ShouldEqual, "")
+				So(cfg.RankingBoosts, ShouldBeEmpty)
 				So(cfg.SearchAPIURL, ShouldEqual, "http://localhost:23100")
+				So(cfg.SearchFixturesPath, ShouldEqual, "search-fixtures")
 				So(cfg.ServiceAuthToken, ShouldEqual, "a507f722-f25a-4889-9653-23a2655b925c")
 				So(cfg.EnableURLRewriting, ShouldEqual, false)
 			})
+
+			Convey("The kafka SASL password should be omitted when the config is logged", func() {
+				cfg.KafkaSASLPassword = "kafka-secret"
+				So(cfg.String(), ShouldNotContainSubstring, "kafka-secret")
+				So(cfg.String(), ShouldNotContainSubstring, "KafkaSASLPassword")
+				cfg.KafkaSASLPassword = ""
+			})
+		})
+	})
+}
+
+func TestRankingBoostsDecode(t *testing.T) {
+	Convey("Given ranking boosts for a dimension as json", t, func() {
+		boosts := config.RankingBoosts{}
+		err := boosts.Decode(`{"geography":{"number_of_children":1.5,"has_data":2,"depth":0.5}}`)
+
+		Convey("Then they are decoded by dimension", func() {
+			So(err, ShouldBeNil)
+			So(boosts, ShouldResemble, config.RankingBoosts{"geography": {Children: 1.5, HasData: 2, Depth: 0.5}})
+		})
+	})
+
+	Convey("Given ranking boosts which are not json", t, func() {
+		boosts := config.RankingBoosts{}
+
+		Convey("Then they cannot be decoded", func() {
+			So(boosts.Decode("geography:2"), ShouldNotBeNil)
 		})
 	})
 }
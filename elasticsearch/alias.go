@@ -0,0 +1,251 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// VersionSeparator separates the alias from the version in the name of a concrete search index,
+// e.g. the alias {instanceID}_{dimension} points at an index named {instanceID}_{dimension}.{version}
+const VersionSeparator = "."
+
+// AliasName returns the name searches are made against for an instance dimension
+func AliasName(instanceID, dimension string) string {
+	return instanceID + "_" + dimension
+}
+
+// aliasActions represents the request body to the _aliases endpoint, all actions are applied atomically
+type aliasActions struct {
+	Actions []aliasAction `json:"actions"`
+}
+
+// aliasAction represents a single change to an alias
+type aliasAction struct {
+	Add         *aliasTarget `json:"add,omitempty"`
+	Remove      *aliasTarget `json:"remove,omitempty"`
+	RemoveIndex *aliasTarget `json:"remove_index,omitempty"`
+}
+
+// aliasTarget represents the index, and optionally the alias, an action applies to
+type aliasTarget struct {
+	Index string `json:"index"`
+	Alias string `json:"alias,omitempty"`
+}
+
+// catAlias represents a single entry returned by the _cat/aliases endpoint
+type catAlias struct {
+	Alias string `json:"alias"`
+	Index string `json:"index"`
+}
+
+// versionedIndex represents a single entry returned by the _cat/indices endpoint with its creation date
+type versionedIndex struct {
+	Index        string `json:"index"`
+	CreationDate string `json:"creation.date"`
+}
+
+// SwapAlias atomically points the alias for an instance dimension at a new versioned index. An index
+// created before aliases were introduced, and named the same as the alias, cannot be kept alongside it,
+// so the swap is refused unless replaceLegacy is set, in which case that index is deleted. It cannot be
+// rolled back to.
+func (api *API) SwapAlias(ctx context.Context, instanceID, dimension, index string, replaceLegacy bool) (*models.AliasSwap, error) {
+	alias := AliasName(instanceID, dimension)
+	logData := log.Data{"alias": alias, "index": index}
+
+	if !strings.HasPrefix(index, alias+VersionSeparator) {
+		return nil, errs.ErrInvalidAliasTarget
+	}
+
+	versions, err := api.listVersionedIndexes(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	for _, version := range versions {
+		found = found || version.Index == index
+	}
+	if !found {
+		return nil, errs.ErrAliasTargetNotFound
+	}
+
+	legacy, err := api.indexExists(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+	if legacy && !replaceLegacy {
+		log.Warn(ctx, "refusing to swap alias, which would delete the index named as the alias", logData)
+		return nil, errs.ErrLegacyIndexExists
+	}
+
+	swap, err := api.pointAlias(ctx, alias, index, legacy)
+	if err != nil {
+		log.Error(ctx, "failed to swap alias", err, logData)
+		return nil, err
+	}
+
+	log.Info(ctx, "alias swapped", log.Data{"alias": alias, "index": index, "previous_index": swap.PreviousIndex})
+
+	return swap, nil
+}
+
+// RollbackAlias atomically points the alias for an instance dimension back at the versioned
+// index created before the one it currently points at
+func (api *API) RollbackAlias(ctx context.Context, instanceID, dimension string) (*models.AliasSwap, error) {
+	alias := AliasName(instanceID, dimension)
+	logData := log.Data{"alias": alias}
+
+	current, err := api.getAliasTargets(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+	if len(current) != 1 {
+		return nil, errs.ErrNoPreviousIndex
+	}
+
+	versions, err := api.listVersionedIndexes(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := ""
+	for i, version := range versions {
+		if version.Index == current[0] && i > 0 {
+			previous = versions[i-1].Index
+		}
+	}
+	if previous == "" {
+		return nil, errs.ErrNoPreviousIndex
+	}
+
+	// an alias cannot share its name with an index, so there is no index created before aliases to remove
+	swap, err := api.pointAlias(ctx, alias, previous, false)
+	if err != nil {
+		log.Error(ctx, "failed to roll back alias", err, logData)
+		return nil, err
+	}
+
+	log.Info(ctx, "alias rolled back", log.Data{"alias": alias, "index": previous, "previous_index": swap.PreviousIndex})
+
+	return swap, nil
+}
+
+// pointAlias removes the alias from every index it currently points at and adds it to index in a single
+// request, deleting the index named as the alias when removeLegacy is set
+func (api *API) pointAlias(ctx context.Context, alias, index string, removeLegacy bool) (*models.AliasSwap, error) {
+	current, err := api.getAliasTargets(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	body := aliasActions{}
+	for _, target := range current {
+		body.Actions = append(body.Actions, aliasAction{Remove: &aliasTarget{Index: target, Alias: alias}})
+	}
+	if removeLegacy {
+		// an index cannot share its name with an alias
+		body.Actions = append(body.Actions, aliasAction{RemoveIndex: &aliasTarget{Index: alias}})
+	}
+	body.Actions = append(body.Actions, aliasAction{Add: &aliasTarget{Index: index, Alias: alias}})
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, errs.ErrMarshallingQuery
+	}
+
+	if _, _, err = api.CallElastic(ctx, api.url+"/_aliases", "POST", payload); err != nil {
+		return nil, err
+	}
+
+	swap := &models.AliasSwap{
+		Alias: alias,
+		Index: index,
+	}
+	if len(current) > 0 {
+		swap.PreviousIndex = current[0]
+	}
+
+	return swap, nil
+}
+
+// getAliasTargets returns the indexes an alias points at
+func (api *API) getAliasTargets(ctx context.Context, alias string) ([]string, error) {
+	responseBody, status, err := api.CallElastic(ctx, api.url+"/_alias/"+alias, "GET", nil)
+	if err != nil {
+		if status == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	targets := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(responseBody, &targets); err != nil {
+		return nil, errs.ErrUnmarshallingJSON
+	}
+
+	indexes := make([]string, 0, len(targets))
+	for index := range targets {
+		indexes = append(indexes, index)
+	}
+	sort.Strings(indexes)
+
+	return indexes, nil
+}
+
+// listVersionedIndexes returns the concrete indexes created for an alias, oldest first
+func (api *API) listVersionedIndexes(ctx context.Context, alias string) ([]versionedIndex, error) {
+	path := api.url + "/_cat/indices/" + alias + VersionSeparator + "*?format=json&h=index,creation.date"
+
+	responseBody, _, err := api.CallElastic(ctx, path, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []versionedIndex
+	if err = json.Unmarshal(responseBody, &versions); err != nil {
+		return nil, errs.ErrUnmarshallingJSON
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, _ := strconv.ParseInt(versions[i].CreationDate, 10, 64)
+		b, _ := strconv.ParseInt(versions[j].CreationDate, 10, 64)
+		if a == b {
+			return versions[i].Index < versions[j].Index
+		}
+		return a < b
+	})
+
+	return versions, nil
+}
+
+// indexExists reports whether a concrete index, rather than an alias, exists with the given name
+func (api *API) indexExists(ctx context.Context, name string) (bool, error) {
+	responseBody, status, err := api.CallElastic(ctx, api.url+"/_cat/indices/"+name+"?format=json&h=index", "GET", nil)
+	if err != nil {
+		if status == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	var indices []catIndex
+	if err = json.Unmarshal(responseBody, &indices); err != nil {
+		return false, errs.ErrUnmarshallingJSON
+	}
+
+	for _, index := range indices {
+		if index.Index == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,159 @@
+package elasticsearch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSwapAlias(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given an alias pointing at the first version of an index", t, func() {
+		cluster := newFakeCluster()
+		cluster.addIndex("123_aggregate.1", 1000)
+		cluster.addIndex("123_aggregate.2", 2000)
+		cluster.aliases["123_aggregate"] = "123_aggregate.1"
+
+		api, server := cluster.newAPI()
+		defer server.Close()
+
+		Convey("When the alias is swapped to the second version", func() {
+			swap, err := api.SwapAlias(ctx, "123", "aggregate", "123_aggregate.2", false)
+
+			Convey("Then the alias points at the second version and the first is reported as the previous index", func() {
+				So(err, ShouldBeNil)
+				So(swap.Alias, ShouldEqual, "123_aggregate")
+				So(swap.Index, ShouldEqual, "123_aggregate.2")
+				So(swap.PreviousIndex, ShouldEqual, "123_aggregate.1")
+				So(cluster.aliases["123_aggregate"], ShouldEqual, "123_aggregate.2")
+			})
+
+			Convey("Then rolling back points the alias at the first version again", func() {
+				swap, err = api.RollbackAlias(ctx, "123", "aggregate")
+				So(err, ShouldBeNil)
+				So(swap.Index, ShouldEqual, "123_aggregate.1")
+				So(swap.PreviousIndex, ShouldEqual, "123_aggregate.2")
+				So(cluster.aliases["123_aggregate"], ShouldEqual, "123_aggregate.1")
+			})
+		})
+
+		Convey("When the alias is swapped to an index which is not one of its versions an error is returned", func() {
+			swap, err := api.SwapAlias(ctx, "123", "aggregate", "456_aggregate.1", false)
+			So(err, ShouldEqual, errs.ErrInvalidAliasTarget)
+			So(swap, ShouldBeNil)
+			So(cluster.aliases["123_aggregate"], ShouldEqual, "123_aggregate.1")
+		})
+
+		Convey("When the alias is swapped to a version which does not exist an error is returned", func() {
+			swap, err := api.SwapAlias(ctx, "123", "aggregate", "123_aggregate.3", false)
+			So(err, ShouldEqual, errs.ErrAliasTargetNotFound)
+			So(swap, ShouldBeNil)
+		})
+
+		Convey("When the alias is rolled back there is no previous version so an error is returned", func() {
+			swap, err := api.RollbackAlias(ctx, "123", "aggregate")
+			So(err, ShouldEqual, errs.ErrNoPreviousIndex)
+			So(swap, ShouldBeNil)
+			So(cluster.aliases["123_aggregate"], ShouldEqual, "123_aggregate.1")
+		})
+	})
+
+	Convey("Given an index created before aliases were introduced", t, func() {
+		cluster := newFakeCluster()
+		cluster.addIndex("123_aggregate", 1000)
+		cluster.addIndex("123_aggregate.1", 2000)
+
+		api, server := cluster.newAPI()
+		defer server.Close()
+
+		Convey("When an alias is swapped to a new version the swap is refused and the old index kept", func() {
+			swap, err := api.SwapAlias(ctx, "123", "aggregate", "123_aggregate.1", false)
+			So(err, ShouldEqual, errs.ErrLegacyIndexExists)
+			So(swap, ShouldBeNil)
+			So(cluster.aliases, ShouldNotContainKey, "123_aggregate")
+			So(cluster.indexes, ShouldContainKey, "123_aggregate")
+		})
+
+		Convey("When an alias is swapped to a new version replacing the old index, the old index is replaced by the alias", func() {
+			swap, err := api.SwapAlias(ctx, "123", "aggregate", "123_aggregate.1", true)
+			So(err, ShouldBeNil)
+			So(swap.PreviousIndex, ShouldEqual, "")
+			So(cluster.aliases["123_aggregate"], ShouldEqual, "123_aggregate.1")
+			So(cluster.indexes, ShouldNotContainKey, "123_aggregate")
+
+			Convey("Then the alias cannot be rolled back to the old index", func() {
+				swap, err = api.RollbackAlias(ctx, "123", "aggregate")
+				So(err, ShouldEqual, errs.ErrNoPreviousIndex)
+				So(swap, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestDeleteSearchIndex(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given an alias pointing at one of two versions of an index", t, func() {
+		cluster := newFakeCluster()
+		cluster.addIndex("123_aggregate.1", 1000)
+		cluster.addIndex("123_aggregate.2", 2000)
+		cluster.addIndex("456_aggregate.1", 2000)
+		cluster.aliases["123_aggregate"] = "123_aggregate.2"
+
+		api, server := cluster.newAPI()
+		defer server.Close()
+
+		Convey("When the search index is deleted every version and the alias are removed", func() {
+			status, err := api.DeleteSearchIndex(ctx, "123", "aggregate")
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, http.StatusOK)
+			So(cluster.indexes, ShouldResemble, map[string]int64{"456_aggregate.1": 2000})
+			So(cluster.aliases, ShouldBeEmpty)
+		})
+
+		Convey("When a search index which does not exist is deleted an error is returned", func() {
+			status, err := api.DeleteSearchIndex(ctx, "789", "aggregate")
+			So(err, ShouldEqual, errs.ErrDeleteIndexNotFound)
+			So(status, ShouldEqual, http.StatusNotFound)
+		})
+	})
+
+	Convey("Given an index created before aliases were introduced", t, func() {
+		cluster := newFakeCluster()
+		cluster.addIndex("123_aggregate", 1000)
+
+		api, server := cluster.newAPI()
+		defer server.Close()
+
+		Convey("When the search index is deleted the index is removed", func() {
+			status, err := api.DeleteSearchIndex(ctx, "123", "aggregate")
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, http.StatusOK)
+			So(cluster.indexes, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestListSearchIndexes(t *testing.T) {
+	Convey("Given a cluster holding aliased, unaliased and legacy indexes", t, func() {
+		cluster := newFakeCluster()
+		cluster.addIndex("123_aggregate.1", 1000)
+		cluster.addIndex("123_aggregate.2", 2000)
+		cluster.addIndex("456_geography", 1000)
+		cluster.addIndex(".kibana_1", 1000)
+		cluster.aliases["123_aggregate"] = "123_aggregate.2"
+
+		api, server := cluster.newAPI()
+		defer server.Close()
+
+		Convey("When the search indexes are listed only the names that can be searched are returned", func() {
+			names, err := api.ListSearchIndexes(context.Background())
+			So(err, ShouldBeNil)
+			So(names, ShouldResemble, []string{"123_aggregate", "456_geography"})
+		})
+	})
+}
@@ -4,43 +4,100 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/elasticsearch/query"
 	"github.com/ONSdigital/dp-dimension-search-api/models"
 	esauth "github.com/ONSdigital/dp-elasticsearch/v2/awsauth"
 	dphttp "github.com/ONSdigital/dp-net/http"
 	"github.com/ONSdigital/log.go/v2/log"
 )
 
+const (
+	// retryBaseDelay is the longest wait before the first retry of a failed call to elasticsearch, doubling for each further retry
+	retryBaseDelay = 100 * time.Millisecond
+
+	// maxRetryDelay caps the wait between retries of a failed call to elasticsearch
+	maxRetryDelay = 5 * time.Second
+)
+
 // API aggregates a client and URL and other common data for accessing the API
 type API struct {
-	awsRegion    string
-	awsSDKSigner *esauth.Signer
-	awsService   string
-	client       dphttp.Clienter
-	url          string
-	signRequests bool
+	awsRegion      string
+	awsSDKSigner   *esauth.Signer
+	awsService     string
+	backend        string
+	boosts         map[string]models.RankingBoost
+	client         dphttp.Clienter
+	endpoints      []*Endpoint
+	maxRetries     int
+	nextRead       atomic.Uint64
+	readMode       string
+	retryBaseDelay time.Duration
+	timeout        time.Duration
+	url            string
+	signRequests   bool
 }
 
-// NewElasticSearchAPI creates an API object
-func NewElasticSearchAPI(client dphttp.Clienter, elasticSearchAPIURL string, signRequests bool, awsSDKSigner *esauth.Signer, awsService, awsRegion string) *API {
+// NewElasticSearchAPI creates an API object for backend, one of elasticsearch, opensearch or opensearch-serverless.
+// Searches are read from endpoints according to readMode, every other call is made to the first, primary, endpoint.
+// Idempotent calls which fail are retried up to maxRetries times, and each attempt is given up after timeout,
+// unless timeout is 0. Searches of a dimension in boosts are ranked with its boost.
+func NewElasticSearchAPI(client dphttp.Clienter, endpoints []*Endpoint, readMode, backend string, signRequests bool, awsSDKSigner *esauth.Signer, awsService, awsRegion string, maxRetries int, timeout time.Duration, boosts map[string]models.RankingBoost) *API {
 	return &API{
-		awsSDKSigner: awsSDKSigner,
-		client:       client,
-		url:          elasticSearchAPIURL,
-		awsRegion:    awsRegion,
-		awsService:   awsService,
-		signRequests: signRequests,
+		awsSDKSigner:   awsSDKSigner,
+		backend:        backend,
+		boosts:         boosts,
+		client:         client,
+		endpoints:      endpoints,
+		maxRetries:     maxRetries,
+		readMode:       readMode,
+		retryBaseDelay: retryBaseDelay,
+		timeout:        timeout,
+		url:            endpoints[0].URL,
+		awsRegion:      awsRegion,
+		awsService:     awsService,
+		signRequests:   signRequests,
 	}
 }
 
-// DeleteSearchIndex removes an index from elasticsearch
+// DeleteSearchIndex removes every version of the index an instance dimension is searched through,
+// along with its alias, or the index itself if it was created before aliases were introduced
 func (api *API) DeleteSearchIndex(ctx context.Context, instanceID, dimension string) (int, error) {
-	path := api.url + "/" + instanceID + "_" + dimension
+	alias := AliasName(instanceID, dimension)
+
+	versions, err := api.listVersionedIndexes(ctx, alias)
+	if err != nil {
+		return 0, err
+	}
+
+	names := make([]string, 0, len(versions)+1)
+	for _, version := range versions {
+		names = append(names, version.Index)
+	}
+
+	legacy, err := api.indexExists(ctx, alias)
+	if err != nil {
+		return 0, err
+	}
+	if legacy {
+		names = append(names, alias)
+	}
+
+	if len(names) == 0 {
+		return http.StatusNotFound, errs.ErrDeleteIndexNotFound
+	}
+
+	path := api.url + "/" + strings.Join(names, ",")
 
 	_, status, err := api.CallElastic(ctx, path, "DELETE", nil)
 	if err != nil {
@@ -54,52 +111,121 @@ func (api *API) DeleteSearchIndex(ctx context.Context, instanceID, dimension str
 	return status, nil
 }
 
-// QuerySearchIndex builds query as a json body to call an elasticsearch index with
-func (api *API) QuerySearchIndex(ctx context.Context, instanceID, dimension, term string, limit, offset int) (*models.SearchResponse, int, error) {
-	response := &models.SearchResponse{}
+// ListSearchIndexes returns the names, matching the {instanceID}_{dimension} naming pattern, that can be
+// searched against. These are the aliases pointing at versioned indexes, and any indexes created before
+// aliases were introduced.
+func (api *API) ListSearchIndexes(ctx context.Context) ([]string, error) {
+	aliasesPath := api.url + "/_cat/aliases/*_*?format=json&h=alias,index"
 
-	path := api.url + "/" + instanceID + "_" + dimension + "/_search"
-
-	logData := log.Data{"term": term, "path": path}
+	responseBody, _, err := api.CallElastic(ctx, aliasesPath, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
 
-	log.Info(ctx, "searching index", logData)
+	var aliases []catAlias
+	if err = json.Unmarshal(responseBody, &aliases); err != nil {
+		log.Error(ctx, "unable to unmarshal list of aliases", err, log.Data{"path": aliasesPath})
+		return nil, errs.ErrUnmarshallingJSON
+	}
 
-	body := buildSearchQuery(term, limit, offset)
+	indicesPath := api.url + "/_cat/indices/*_*?format=json&h=index"
 
-	bytes, err := json.Marshal(body)
+	responseBody, _, err = api.CallElastic(ctx, indicesPath, "GET", nil)
 	if err != nil {
-		log.Error(ctx, "unable to marshal elastic search query to bytes", err, logData)
-		return nil, 0, errs.ErrMarshallingQuery
+		return nil, err
 	}
 
-	logData["request_body"] = string(bytes)
+	var indices []catIndex
+	if err = json.Unmarshal(responseBody, &indices); err != nil {
+		log.Error(ctx, "unable to unmarshal list of indexes", err, log.Data{"path": indicesPath})
+		return nil, errs.ErrUnmarshallingJSON
+	}
+
+	names := make([]string, 0, len(aliases)+len(indices))
+	seen := make(map[string]bool)
+	for _, alias := range aliases {
+		if !seen[alias.Alias] && !strings.HasPrefix(alias.Alias, ".") {
+			names = append(names, alias.Alias)
+		}
+		seen[alias.Alias] = true
+	}
 
-	responseBody, status, err := api.CallElastic(ctx, path, "GET", bytes)
+	for _, index := range indices {
+		// skip system and hidden indexes, and versioned indexes which are searched through an alias
+		if strings.HasPrefix(index.Index, ".") || strings.Contains(index.Index, VersionSeparator) {
+			continue
+		}
+		names = append(names, index.Index)
+	}
+
+	return names, nil
+}
+
+// QuerySearchIndex builds query as a json body to call an elasticsearch index with. The search is read from
+// another cluster, if there is one, when a cluster cannot handle it. Unless exactTotal is true, the total may
+// be a lower bound, with a relation of gte, when there are more than 10,000 matches.
+func (api *API) QuerySearchIndex(ctx context.Context, instanceID, dimension, term string, limit, offset int, exactTotal bool) (*models.SearchResponse, int, error) {
+	path := "/" + AliasName(instanceID, dimension) + "/_search"
+
+	logData := log.Data{"term": term, "path": path, "exact_total": exactTotal}
+
+	log.Info(ctx, "searching index", logData)
+
+	var boost *models.RankingBoost
+	if b, ok := api.boosts[dimension]; ok {
+		boost = &b
+		logData["boost"] = b
+	}
+
+	var codec Codec
+	var responseBody []byte
+	var status int
+	var err error
+	for _, endpoint := range api.readEndpoints() {
+		// each cluster may be a different version, so is searched with the codec for its own version
+		codec = api.searchCodec(ctx, endpoint)
+		logData["codec"] = codec.Name()
+
+		var bytes []byte
+		if bytes, err = codec.EncodeSearchQuery(term, limit, offset, boost, exactTotal); err != nil {
+			log.Error(ctx, "unable to marshal elastic search query to bytes", err, logData)
+			return nil, 0, errs.ErrMarshallingQuery
+		}
+		logData["request_body"] = string(bytes)
+
+		responseBody, status, err = api.callEndpoint(ctx, endpoint, endpoint.URL+path, "GET", bytes)
+		if err == nil || !canFailOver(status, err) {
+			endpoint.healthy.Store(true)
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		endpoint.healthy.Store(false)
+		log.Warn(ctx, "search failed against elasticsearch cluster, trying the next cluster", logData)
+	}
 	logData["status"] = status
-	if err != nil {
+	switch {
+	case err == nil:
+	case errors.Is(err, errs.ErrElasticsearchUnavailable):
+		return nil, status, err
+	case status == http.StatusNotFound:
+		log.Info(ctx, "search index not found", logData)
+		return nil, status, errs.ErrDimensionNotSearchable
+	case isTimeout(err) || status == http.StatusGatewayTimeout:
+		log.Error(ctx, "elasticsearch did not respond in time", err, logData)
+		return nil, http.StatusGatewayTimeout, errs.ErrElasticsearchTimeout
+	default:
 		log.Error(ctx, "failed to call elasticsearch", err, logData)
-		return nil, status, errs.ErrIndexNotFound
+		return nil, status, err
 	}
 
 	logData["response_body"] = string(responseBody)
 
-	if err = json.Unmarshal(responseBody, response); err != nil {
-		log.Info(ctx, "unable to unmarshal json body - trying ES6 struct instead", logData)
-
-		// If we fail to marshall to ES7 then try the ES6 response struct instead
-		responseEs6 := &models.SearchResponseES6{}
-		if err6 := json.Unmarshal(responseBody, responseEs6); err6 != nil {
-			log.Error(ctx, "unable to unmarshal json body to ES7 struct", err, logData)
-			log.Error(ctx, "unable to unmarshal json body to ES6 struct", err6, logData)
-			return nil, status, errs.ErrUnmarshallingJSON
-		}
-		response = &models.SearchResponse{Hits: models.Hits{
-			Total: models.Total{
-				Value:    responseEs6.Hits.Total,
-				Relation: "eq",
-			},
-			HitList: responseEs6.Hits.HitList,
-		}}
+	response, err := codec.DecodeSearchResponse(responseBody)
+	if err != nil {
+		log.Error(ctx, "unable to unmarshal json body", err, logData)
+		return nil, status, errs.ErrUnmarshallingJSON
 	}
 
 	log.Info(ctx, "search results", logData)
@@ -107,8 +233,14 @@ func (api *API) QuerySearchIndex(ctx context.Context, instanceID, dimension, ter
 	return response, status, nil
 }
 
-// CallElastic builds a request to elastic search based on the method, path and payload
+// CallElastic builds a request to elastic search based on the method, path and payload. Idempotent calls are
+// retried, with jittered exponential backoff, when elasticsearch cannot be reached or responds with 429 or 5xx.
 func (api *API) CallElastic(ctx context.Context, path, method string, payload interface{}) (responseBody []byte, statusCode int, err error) {
+	return api.callEndpoint(ctx, api.endpoints[0], path, method, payload)
+}
+
+// callEndpoint makes a call to the cluster at endpoint, through its circuit breaker
+func (api *API) callEndpoint(ctx context.Context, endpoint *Endpoint, path, method string, payload interface{}) (responseBody []byte, statusCode int, err error) {
 	logData := log.Data{"url": path, "method": method}
 
 	URL, err := url.Parse(path)
@@ -119,33 +251,103 @@ func (api *API) CallElastic(ctx context.Context, path, method string, payload in
 	path = URL.String()
 	logData["url"] = path
 
+	var body []byte
+	if payload != nil {
+		body = payload.([]byte)
+		logData["payload"] = string(body)
+	}
+
+	maxRetries := 0
+	if isIdempotent(method) {
+		maxRetries = api.maxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		var retryable bool
+		responseBody, statusCode, retryable, err = api.callElasticThroughBreaker(ctx, endpoint, path, method, body, logData)
+		if err == nil || !retryable || attempt >= maxRetries || ctx.Err() != nil {
+			return responseBody, statusCode, err
+		}
+
+		delay := api.retryDelay(attempt)
+		logData["attempt"] = attempt + 1
+		logData["retry_in"] = delay.String()
+		log.Warn(ctx, "call to elastic failed, will retry", logData)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, statusCode, ctx.Err()
+		}
+	}
+}
+
+// callElasticThroughBreaker makes a single attempt at a call to elasticsearch unless the circuit breaker is open,
+// recording whether elasticsearch failed to handle the call
+func (api *API) callElasticThroughBreaker(ctx context.Context, endpoint *Endpoint, path, method string, body []byte, logData log.Data) (responseBody []byte, statusCode int, retryable bool, err error) {
+	breaker := endpoint.breaker
+	if breaker == nil {
+		return api.callElasticOnce(ctx, endpoint, path, method, body, logData)
+	}
+
+	if err = breaker.Allow(); err != nil {
+		log.Error(ctx, "circuit breaker is open, not calling elastic", err, logData)
+		return nil, 0, false, err
+	}
+
+	responseBody, statusCode, retryable, err = api.callElasticOnce(ctx, endpoint, path, method, body, logData)
+	if ctx.Err() != nil {
+		// the call was given up by the caller, which says nothing about the health of elasticsearch
+		breaker.Release()
+	} else {
+		breaker.Record(err != nil && retryable)
+	}
+
+	return responseBody, statusCode, retryable, err
+}
+
+// callElasticOnce makes a single attempt at a call to elasticsearch, reporting whether a failed attempt can be retried.
+// The request is built, and signed, afresh so that the body is read from the start on each attempt.
+func (api *API) callElasticOnce(ctx context.Context, endpoint *Endpoint, path, method string, body []byte, logData log.Data) (responseBody []byte, statusCode int, retryable bool, err error) {
+	if api.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, api.timeout)
+		defer cancel()
+	}
+
 	var req *http.Request
 	var bodyReader io.ReadSeeker
 
-	if payload != nil {
-		req, err = http.NewRequest(method, path, bytes.NewReader(payload.([]byte)))
-		req.Header.Add("Content-type", "application/json")
-		logData["payload"] = string(payload.([]byte))
-		bodyReader = bytes.NewReader(payload.([]byte))
+	if body != nil {
+		req, err = http.NewRequest(method, path, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Add("Content-type", "application/json")
+		}
+		bodyReader = bytes.NewReader(body)
 	} else {
 		req, err = http.NewRequest(method, path, http.NoBody)
 	}
 	// check req, above, didn't error
 	if err != nil {
 		log.Error(ctx, "failed to create request for call to elastic", err, logData)
-		return nil, 0, err
+		return nil, 0, false, err
 	}
 
 	if api.signRequests {
+		if api.awsService == SigningServiceServerless {
+			setContentSHA256(req, body)
+		}
 		if signErr := api.awsSDKSigner.Sign(req, bodyReader, time.Now()); signErr != nil {
-			return nil, 0, signErr
+			return nil, 0, false, signErr
 		}
 	}
 
 	resp, err := api.client.Do(ctx, req)
 	if err != nil {
 		log.Error(ctx, "failed to call elastic", err, logData)
-		return nil, 0, err
+		// the cluster may have been replaced, perhaps by another version, before it can be reached again
+		endpoint.markVersionStale()
+		return nil, 0, true, err
 	}
 	defer resp.Body.Close()
 
@@ -154,112 +356,100 @@ func (api *API) CallElastic(ctx context.Context, path, method string, payload in
 	jsonBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Error(ctx, "failed to read response body from call to elastic", err, logData)
-		return nil, resp.StatusCode, err
+		return nil, resp.StatusCode, true, err
 	}
 	logData["json_body"] = string(jsonBody)
 
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= 300 {
 		log.Error(ctx, errs.ErrUnexpectedStatusCode.Error(), errs.ErrUnexpectedStatusCode, logData)
-		return nil, resp.StatusCode, errs.ErrUnexpectedStatusCode
+		retryable = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+		return nil, resp.StatusCode, retryable, errs.ErrUnexpectedStatusCode
 	}
 
-	return jsonBody, resp.StatusCode, nil
-}
-
-// Body represents the request body to elasticsearch
-type Body struct {
-	From      int        `json:"from"`
-	Size      int        `json:"size"`
-	Highlight *Highlight `json:"highlight,omitempty"`
-	Query     Query      `json:"query"`
-	Sort      []Scores   `json:"sort"`
+	return jsonBody, resp.StatusCode, false, nil
 }
 
-// Highlight represents parts of the fields that matched
-type Highlight struct {
-	PreTags  []string          `json:"pre_tags,omitempty"`
-	PostTags []string          `json:"post_tags,omitempty"`
-	Fields   map[string]Object `json:"fields,omitempty"`
-	Order    string            `json:"score,omitempty"`
-}
-
-// Object represents an empty object (as expected by elasticsearch)
-type Object struct{}
-
-// Query represents the request query details
-type Query struct {
-	Bool Bool `json:"bool"`
-}
-
-// Bool represents the desirable goals for query
-type Bool struct {
-	Must   []Match `json:"must,omitempty"`
-	Should []Match `json:"should,omitempty"`
+// retryDelay returns a random wait, up to double that of the previous attempt, before retrying a failed call
+func (api *API) retryDelay(attempt int) time.Duration {
+	ceiling := api.retryBaseDelay << attempt
+	if ceiling <= 0 || ceiling > maxRetryDelay {
+		ceiling = maxRetryDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)) + 1)
 }
 
-// Match represents the fields that the term should or must match within query
-type Match struct {
-	Match map[string]string `json:"match,omitempty"`
+// isTimeout reports whether err is from a call to elasticsearch which was given up as it took too long
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
 }
 
-// Scores represents a list of scoring, e.g. scoring on relevance, but can add in secondary
-// score such as alphabetical order if relevance is the same for two search results
-type Scores struct {
-	Score Score `json:"_score"`
+// isIdempotent reports whether a call with method can be repeated without changing its outcome
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
 }
 
-// Score contains the ordering of the score (ascending or descending)
-type Score struct {
-	Order string `json:"order"`
+// catIndex represents a single entry returned by the _cat/indices endpoint
+type catIndex struct {
+	Index string `json:"index"`
 }
 
-func buildSearchQuery(term string, limit, offset int) *Body {
-	var object Object
-	highlight := make(map[string]Object)
-
-	highlight["label"] = object
-	highlight["code"] = object
-
-	label := make(map[string]string)
-	code := make(map[string]string)
-	label["label"] = term
-	code["code"] = term
-
-	labelMatch := Match{
-		Match: label,
+// buildSearchQuery returns a search matching term in the label or code, ranked with boost if it is not nil
+func buildSearchQuery(term string, limit, offset int, boost *models.RankingBoost) *query.Request {
+	var match query.Clause = query.Bool().Should(
+		query.Match("label", term),
+		query.Match("code", term),
+	)
+	if boost != nil {
+		match = rankingBoost(match, boost)
 	}
 
-	codeMatch := Match{
-		Match: code,
-	}
-
-	scores := Scores{
-		Score: Score{
-			Order: "desc",
-		},
-	}
-
-	listOfScores := []Scores{}
-	listOfScores = append(listOfScores, scores)
-
-	query := &Body{
+	return &query.Request{
 		From: offset,
 		Size: limit,
-		Highlight: &Highlight{
+		Highlight: &query.Highlight{
 			PreTags:  []string{"\u0001S"},
 			PostTags: []string{"\u0001E"},
-			Fields:   highlight,
-		},
-		Query: Query{
-			Bool: Bool{
-				Should: []Match{
-					labelMatch,
-					codeMatch,
-				},
+			Fields: map[string]query.HighlightField{
+				"label": {},
+				"code":  {},
 			},
 		},
-		Sort: listOfScores,
+		Query: match,
+		Sort:  searchOrder(),
+	}
+}
+
+// searchOrder orders search results by relevance, then label, then code. Without the label and code, equally
+// relevant results may be returned in a different order by each request, so that they repeat, or are missed,
+// when paging through them. Indexes created before the index template sort labels by case, and those without
+// the keyword sub-fields are sorted by relevance alone, as no field of theirs is both unique and sortable.
+func searchOrder() []query.Sort {
+	return []query.Sort{
+		query.ScoreSort(query.Descending),
+		query.SortField("label.keyword", query.Ascending).Unmapped("keyword"),
+		query.SortField("code.keyword", query.Ascending).Unmapped("keyword"),
+	}
+}
+
+// rankingBoost returns match with its score multiplied by 1 plus each of the boosts which is set
+func rankingBoost(match query.Clause, boost *models.RankingBoost) query.Clause {
+	functions := []query.Function{query.Weight(1, nil)}
+	if boost.Children != 0 {
+		functions = append(functions, query.FieldValueFactor("number_of_children").Factor(boost.Children).Modifier("log1p").Missing(0))
+	}
+	if boost.HasData != 0 {
+		functions = append(functions, query.Weight(boost.HasData, query.Term("has_data", true)))
+	}
+	if boost.Depth != 0 {
+		// a decay function scores documents without the field as 1, which would give every option indexed
+		// without a depth the whole boost
+		functions = append(functions, query.Decay(query.DecayExp, "depth", 0, 1).Weight(boost.Depth).Filter(query.Exists("depth")))
 	}
 
-	return query
+	return query.FunctionScore(match).Add(functions...).ScoreMode("sum").BoostMode("multiply")
 }
@@ -0,0 +1,315 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	esauth "github.com/ONSdigital/dp-elasticsearch/v2/awsauth"
+	dphttp "github.com/ONSdigital/dp-net/http"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// flakyServer responds with the given status codes in turn, then 200, recording each request it receives
+type flakyServer struct {
+	mu       sync.Mutex
+	statuses []int
+	delay    time.Duration
+	bodies   []string
+	signed   []bool
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.bodies = append(s.bodies, string(body))
+	s.signed = append(s.signed, r.Header.Get("Authorization") != "")
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	s.mu.Unlock()
+
+	time.Sleep(s.delay)
+	w.WriteHeader(status)
+	w.Write([]byte(`{}`))
+}
+
+func (s *flakyServer) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func newRetryingAPI(url string, maxRetries int, timeout time.Duration) *API {
+	client := dphttp.NewClient()
+	client.SetMaxRetries(0)
+	api := NewElasticSearchAPI(client, []*Endpoint{NewEndpoint(url, nil)}, ReadModeFailover, BackendElasticsearch, false, nil, "es", "eu-west-1", maxRetries, timeout, nil)
+	api.retryBaseDelay = time.Millisecond
+	return api
+}
+
+func TestCallElasticRetries(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given elasticsearch is briefly unavailable", t, func() {
+		flaky := &flakyServer{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+		server := httptest.NewServer(flaky)
+		defer server.Close()
+
+		Convey("When an idempotent call is made with retries enabled", func() {
+			api := newRetryingAPI(server.URL, 3, 0)
+			body, status, err := api.CallElastic(ctx, server.URL+"/index/_search", http.MethodGet, []byte(`{"query":{}}`))
+
+			Convey("Then the call is retried until it succeeds, with the full body sent on each attempt", func() {
+				So(err, ShouldBeNil)
+				So(status, ShouldEqual, http.StatusOK)
+				So(string(body), ShouldEqual, `{}`)
+				So(flaky.bodies, ShouldResemble, []string{`{"query":{}}`, `{"query":{}}`, `{"query":{}}`})
+			})
+		})
+
+		Convey("When an idempotent call is made with fewer retries than failures", func() {
+			api := newRetryingAPI(server.URL, 1, 0)
+			_, status, err := api.CallElastic(ctx, server.URL+"/index", http.MethodDelete, nil)
+
+			Convey("Then the last failure is returned", func() {
+				So(err, ShouldEqual, errs.ErrUnexpectedStatusCode)
+				So(status, ShouldEqual, http.StatusTooManyRequests)
+				So(flaky.attempts(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When a call which is not idempotent is made", func() {
+			api := newRetryingAPI(server.URL, 3, 0)
+			_, status, err := api.CallElastic(ctx, server.URL+"/_aliases", http.MethodPost, []byte(`{"actions":[]}`))
+
+			Convey("Then the call is not retried", func() {
+				So(err, ShouldEqual, errs.ErrUnexpectedStatusCode)
+				So(status, ShouldEqual, http.StatusServiceUnavailable)
+				So(flaky.attempts(), ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given elasticsearch rejects a request", t, func() {
+		flaky := &flakyServer{statuses: []int{http.StatusBadRequest}}
+		server := httptest.NewServer(flaky)
+		defer server.Close()
+
+		Convey("When an idempotent call is made with retries enabled", func() {
+			api := newRetryingAPI(server.URL, 3, 0)
+			_, status, err := api.CallElastic(ctx, server.URL+"/index/_search", http.MethodGet, nil)
+
+			Convey("Then the call is not retried", func() {
+				So(err, ShouldEqual, errs.ErrUnexpectedStatusCode)
+				So(status, ShouldEqual, http.StatusBadRequest)
+				So(flaky.attempts(), ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given requests to elasticsearch are signed", t, func() {
+		t.Setenv("AWS_ACCESS_KEY_ID", "test-access-key")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret-key")
+		signer, err := esauth.NewAwsSigner("", "", "eu-west-1", "es")
+		So(err, ShouldBeNil)
+
+		flaky := &flakyServer{statuses: []int{http.StatusBadGateway}}
+		server := httptest.NewServer(flaky)
+		defer server.Close()
+
+		Convey("When a call with a body is retried", func() {
+			api := newRetryingAPI(server.URL, 3, 0)
+			api.signRequests = true
+			api.awsSDKSigner = signer
+			_, _, err := api.CallElastic(ctx, server.URL+"/_template/dimension", http.MethodPut, []byte(`{"index_patterns":["*"]}`))
+
+			Convey("Then each attempt is signed and sends the full body", func() {
+				So(err, ShouldBeNil)
+				So(flaky.signed, ShouldResemble, []bool{true, true})
+				So(flaky.bodies, ShouldResemble, []string{`{"index_patterns":["*"]}`, `{"index_patterns":["*"]}`})
+			})
+		})
+	})
+}
+
+func TestCallElasticTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given elasticsearch is slow to respond", t, func() {
+		flaky := &flakyServer{delay: 200 * time.Millisecond}
+		server := httptest.NewServer(flaky)
+		defer server.Close()
+
+		Convey("When a call is made with a shorter timeout and no retries", func() {
+			api := newRetryingAPI(server.URL, 0, 20*time.Millisecond)
+			started := time.Now()
+			_, _, err := api.CallElastic(ctx, server.URL+"/index/_search", http.MethodGet, nil)
+
+			Convey("Then the call is given up once the timeout has passed", func() {
+				So(err, ShouldNotBeNil)
+				So(time.Since(started), ShouldBeLessThan, 200*time.Millisecond)
+				So(flaky.attempts(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a search is made with a shorter timeout", func() {
+			api := newRetryingAPI(server.URL, 0, 20*time.Millisecond)
+			_, status, err := api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+
+			Convey("Then the search fails as having timed out", func() {
+				So(err, ShouldEqual, errs.ErrElasticsearchTimeout)
+				So(status, ShouldEqual, http.StatusGatewayTimeout)
+			})
+		})
+	})
+
+	Convey("Given elasticsearch times out a search itself", t, func() {
+		server := httptest.NewServer(&readCluster{status: http.StatusGatewayTimeout})
+		defer server.Close()
+
+		Convey("When a search is made", func() {
+			api := newReadAPI(ReadModeFailover, server)
+			_, status, err := api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+
+			Convey("Then the search fails as having timed out", func() {
+				So(err, ShouldEqual, errs.ErrElasticsearchTimeout)
+				So(status, ShouldEqual, http.StatusGatewayTimeout)
+			})
+		})
+	})
+}
+
+func TestBuildSearchQuery(t *testing.T) {
+	Convey("Given a search for a term", t, func() {
+		actual, err := json.Marshal(buildSearchQuery("england", 10, 20, nil))
+		So(err, ShouldBeNil)
+
+		Convey("Then the query matches the label or code, highlighting both, in order of relevance, label and code", func() {
+			expected, err := os.ReadFile("testdata/search_query.json")
+			So(err, ShouldBeNil)
+			So(string(actual), ShouldEqual, string(bytes.TrimSpace(expected)))
+		})
+	})
+
+	Convey("Given a search for a term in a dimension with a ranking boost", t, func() {
+		actual, err := json.Marshal(buildSearchQuery("england", 10, 20, &models.RankingBoost{Children: 1.5, HasData: 2, Depth: 0.5}))
+		So(err, ShouldBeNil)
+
+		Convey("Then the relevance of each match is multiplied by 1 plus its boosts", func() {
+			expected, err := os.ReadFile("testdata/search_query_boosted.json")
+			So(err, ShouldBeNil)
+			So(string(actual), ShouldEqual, string(bytes.TrimSpace(expected)))
+		})
+	})
+
+	Convey("Given a search for a term in a dimension with only some boosts set", t, func() {
+		actual, err := json.Marshal(buildSearchQuery("england", 10, 20, &models.RankingBoost{HasData: 2}))
+		So(err, ShouldBeNil)
+
+		Convey("Then only the boosts which are set are applied", func() {
+			So(string(actual), ShouldContainSubstring, `"functions":[{"weight":1},{"filter":{"term":{"has_data":true}},"weight":2}]`)
+		})
+	})
+
+	Convey("Given a search for a term in a dimension boosted by depth", t, func() {
+		actual, err := json.Marshal(buildSearchQuery("england", 10, 20, &models.RankingBoost{Depth: 2}))
+		So(err, ShouldBeNil)
+
+		Convey("Then options indexed without a depth are not boosted, rather than given the whole boost", func() {
+			So(string(actual), ShouldContainSubstring, `"functions":[{"weight":1},{"exp":{"depth":{"origin":0,"scale":1}},"filter":{"exists":{"field":"depth"}},"weight":2}]`)
+		})
+	})
+}
+
+func TestQuerySearchIndexRankingBoost(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a ranking boost for the geography dimension", t, func() {
+		flaky := &flakyServer{}
+		server := httptest.NewServer(flaky)
+		defer server.Close()
+
+		api := newRetryingAPI(server.URL, 0, 0)
+		api.boosts = map[string]models.RankingBoost{"geography": {Children: 1}}
+
+		Convey("When the geography dimension is searched", func() {
+			_, _, err := api.QuerySearchIndex(ctx, "123", "geography", "england", 10, 0, false)
+			So(err, ShouldBeNil)
+
+			Convey("Then the search is ranked with the boost", func() {
+				So(flaky.bodies[len(flaky.bodies)-1], ShouldContainSubstring, `"function_score"`)
+			})
+		})
+
+		Convey("When another dimension is searched", func() {
+			_, _, err := api.QuerySearchIndex(ctx, "123", "aggregate", "england", 10, 0, false)
+			So(err, ShouldBeNil)
+
+			Convey("Then the search is ranked by relevance alone", func() {
+				So(flaky.bodies[len(flaky.bodies)-1], ShouldNotContainSubstring, `"function_score"`)
+			})
+		})
+	})
+}
+
+func TestQuerySearchIndexPaging(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a cluster holding equally relevant options, some with the same label", t, func() {
+		cluster := newFakeCluster()
+		for i := 0; i < 25; i++ {
+			cluster.options = append(cluster.options, models.SearchResult{
+				Code:  fmt.Sprintf("E%02d", i),
+				Label: []string{"Ward", "ward", "Parish"}[i%3],
+			})
+		}
+		api, server := cluster.newAPI()
+		defer server.Close()
+
+		Convey("When every page of a search is requested", func() {
+			seen := make(map[string]int)
+			var paged []string
+			for offset := 0; offset < 25; offset += 10 {
+				response, _, err := api.QuerySearchIndex(ctx, "123", "geography", "ward", 10, offset, false)
+				So(err, ShouldBeNil)
+				for _, hit := range response.Hits.HitList {
+					seen[hit.Source.Code]++
+					paged = append(paged, hit.Source.Code)
+				}
+			}
+
+			Convey("Then consecutive pages never overlap and every option is returned once", func() {
+				duplicates := []string{}
+				for code, count := range seen {
+					if count > 1 {
+						duplicates = append(duplicates, code)
+					}
+				}
+				So(duplicates, ShouldBeEmpty)
+				So(paged, ShouldHaveLength, 25)
+				So(seen, ShouldHaveLength, 25)
+			})
+
+			Convey("Then the same page is the same on every request", func() {
+				first, _, err := api.QuerySearchIndex(ctx, "123", "geography", "ward", 10, 10, false)
+				So(err, ShouldBeNil)
+				second, _, err := api.QuerySearchIndex(ctx, "123", "geography", "ward", 10, 10, false)
+				So(err, ShouldBeNil)
+				So(second.Hits.HitList, ShouldResemble, first.Hits.HitList)
+			})
+		})
+	})
+}
@@ -0,0 +1,76 @@
+package elasticsearch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+)
+
+// Backends which can be searched
+const (
+	BackendElasticsearch        = "elasticsearch"
+	BackendOpenSearch           = "opensearch"
+	BackendOpenSearchServerless = "opensearch-serverless"
+)
+
+// AWS services which requests are signed for
+const (
+	// SigningServiceManaged covers both elasticsearch and opensearch domains managed by AWS
+	SigningServiceManaged = "es"
+
+	// SigningServiceServerless is opensearch serverless collections
+	SigningServiceServerless = "aoss"
+)
+
+// contentSHA256Header holds the hash of the request body, which opensearch serverless requires on signed requests
+const contentSHA256Header = "X-Amz-Content-Sha256"
+
+// ErrUnsupportedBackend is returned when the backend is not elasticsearch, opensearch or opensearch serverless
+var ErrUnsupportedBackend = errors.New("unsupported search backend, must be elasticsearch, opensearch or opensearch-serverless")
+
+// ValidateBackend returns ErrUnsupportedBackend if backend cannot be searched
+func ValidateBackend(backend string) error {
+	switch backend {
+	case BackendElasticsearch, BackendOpenSearch, BackendOpenSearchServerless:
+		return nil
+	}
+	return ErrUnsupportedBackend
+}
+
+// SigningService returns the AWS service requests to backend are signed for. Opensearch serverless collections
+// are always signed for aoss, otherwise awsService is used.
+func SigningService(backend, awsService string) string {
+	if backend == BackendOpenSearchServerless {
+		return SigningServiceServerless
+	}
+	return awsService
+}
+
+// serverless reports whether the backend is an opensearch serverless collection, which has no cluster level
+// APIs and supports only composable index templates
+func (api *API) serverless() bool {
+	return api.backend == BackendOpenSearchServerless
+}
+
+// setContentSHA256 sets the hash of body on a request to be signed for opensearch serverless, which, unlike
+// managed domains, does not accept signatures without it
+func setContentSHA256(req *http.Request, body []byte) {
+	hash := sha256.Sum256(body)
+	req.Header.Set(contentSHA256Header, hex.EncodeToString(hash[:]))
+}
+
+// Checker returns a health check reporting whether dimension search indexes can be listed from endpoint. It is
+// used in place of the cluster health check for opensearch serverless collections, which have no cluster health API.
+func (api *API) Checker(endpoint *Endpoint) healthcheck.Checker {
+	return func(ctx context.Context, state *healthcheck.CheckState) error {
+		path := endpoint.URL + "/_cat/indices/" + TemplatePattern + "?format=json&h=index"
+		if _, _, err := api.callEndpoint(ctx, endpoint, path, http.MethodGet, nil); err != nil {
+			return state.Update(healthcheck.StatusCritical, "failed to list search indexes: "+err.Error(), 0)
+		}
+		return state.Update(healthcheck.StatusOK, api.backend+" is ok", http.StatusOK)
+	}
+}
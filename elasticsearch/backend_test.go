@@ -0,0 +1,179 @@
+package elasticsearch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	esauth "github.com/ONSdigital/dp-elasticsearch/v2/awsauth"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	dphttp "github.com/ONSdigital/dp-net/http"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// serverlessCollection is a stand-in for an opensearch serverless collection. Like a real collection it has no
+// cluster level APIs or legacy index templates, and rejects signed requests without a matching content hash.
+type serverlessCollection struct {
+	mu        sync.Mutex
+	service   string
+	templates map[string][]byte
+	requests  []string
+}
+
+func newServerlessCollection(service string) *serverlessCollection {
+	return &serverlessCollection{service: service, templates: make(map[string][]byte)}
+}
+
+func (c *serverlessCollection) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests = append(c.requests, r.Method+" "+r.URL.Path)
+	body, _ := io.ReadAll(r.Body)
+
+	authorization := r.Header.Get("Authorization")
+	if !strings.Contains(authorization, "/eu-west-1/"+c.service+"/aws4_request") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if c.service == SigningServiceServerless {
+		hash := sha256.Sum256(body)
+		if r.Header.Get(contentSHA256Header) != hex.EncodeToString(hash[:]) || !strings.Contains(authorization, "x-amz-content-sha256") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/_index_template/"):
+		name := strings.TrimPrefix(r.URL.Path, "/_index_template/")
+		template, ok := c.templates[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"index_templates":[{"name":"` + name + `","index_template":` + string(template) + `}]}`))
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/_index_template/"):
+		c.templates[strings.TrimPrefix(r.URL.Path, "/_index_template/")] = body
+		w.Write([]byte(`{"acknowledged":true}`))
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/_mapping"):
+		w.Write([]byte(`{}`))
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/_cat/indices"):
+		w.Write([]byte(`[{"index":"123_aggregate"}]`))
+	case r.Method == http.MethodGet && r.URL.Path == "/123_aggregate/_search":
+		w.Write([]byte(es7SearchResponse))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newSignedAPI(url, backend string) *API {
+	signer, err := esauth.NewAwsSigner("", "", "eu-west-1", SigningService(backend, SigningServiceManaged))
+	So(err, ShouldBeNil)
+
+	client := dphttp.NewClient()
+	client.SetMaxRetries(0)
+	return NewElasticSearchAPI(client, []*Endpoint{NewEndpoint(url, nil)}, ReadModeFailover, backend, true, signer, SigningService(backend, SigningServiceManaged), "eu-west-1", 0, 0, nil)
+}
+
+func TestBackend(t *testing.T) {
+	Convey("Given the supported backends", t, func() {
+		Convey("Then each is valid", func() {
+			So(ValidateBackend(BackendElasticsearch), ShouldBeNil)
+			So(ValidateBackend(BackendOpenSearch), ShouldBeNil)
+			So(ValidateBackend(BackendOpenSearchServerless), ShouldBeNil)
+			So(ValidateBackend("solr"), ShouldEqual, ErrUnsupportedBackend)
+		})
+
+		Convey("Then only opensearch serverless is signed for aoss", func() {
+			So(SigningService(BackendElasticsearch, "es"), ShouldEqual, SigningServiceManaged)
+			So(SigningService(BackendOpenSearch, "es"), ShouldEqual, SigningServiceManaged)
+			So(SigningService(BackendOpenSearchServerless, "es"), ShouldEqual, SigningServiceServerless)
+		})
+	})
+}
+
+func TestOpenSearchServerless(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given an opensearch serverless collection", t, func() {
+		t.Setenv("AWS_ACCESS_KEY_ID", "test-access-key")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret-key")
+
+		collection := newServerlessCollection(SigningServiceServerless)
+		server := httptest.NewServer(collection)
+		defer server.Close()
+
+		api := newSignedAPI(server.URL, BackendOpenSearchServerless)
+
+		Convey("When the version is detected", func() {
+			version, err := api.DetectVersion(ctx)
+
+			Convey("Then it is searched as opensearch serverless without asking the collection", func() {
+				So(err, ShouldBeNil)
+				So(version.String(), ShouldEqual, "opensearch serverless")
+				So(collection.requests, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a search is made", func() {
+			response, _, err := api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+
+			Convey("Then the signed request, with its content hash, is accepted", func() {
+				So(err, ShouldBeNil)
+				So(response.Hits.Total.Value, ShouldEqual, 2)
+				So(collection.requests, ShouldResemble, []string{"GET /123_aggregate/_search"})
+			})
+		})
+
+		Convey("When the index template is installed", func() {
+			report, err := api.InstallTemplate(ctx)
+
+			Convey("Then it is installed as a composable template and no longer drifts", func() {
+				So(err, ShouldBeNil)
+				So(report.Updated, ShouldBeTrue)
+				So(report.InstalledVersion, ShouldEqual, TemplateVersion)
+				So(report.TemplateDrifted(), ShouldBeFalse)
+				So(collection.templates, ShouldContainKey, TemplateName)
+				So(string(collection.templates[TemplateName]), ShouldContainSubstring, `"template":{"settings"`)
+			})
+		})
+
+		Convey("When the health check is run", func() {
+			state := healthcheck.NewCheckState("Elasticsearch")
+			So(api.Checker(api.endpoints[0])(ctx, state), ShouldBeNil)
+
+			Convey("Then it reports the collection is ok", func() {
+				So(state.Status(), ShouldEqual, healthcheck.StatusOK)
+				So(state.Message(), ShouldEqual, "opensearch-serverless is ok")
+			})
+		})
+	})
+
+	Convey("Given a managed opensearch domain", t, func() {
+		t.Setenv("AWS_ACCESS_KEY_ID", "test-access-key")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret-key")
+
+		domain := newServerlessCollection(SigningServiceManaged)
+		server := httptest.NewServer(domain)
+		defer server.Close()
+
+		api := newSignedAPI(server.URL, BackendOpenSearch)
+
+		Convey("When a search is made before the version can be detected", func() {
+			response, _, err := api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+
+			Convey("Then the request is signed for es and decoded as opensearch", func() {
+				So(err, ShouldBeNil)
+				So(response.Hits.HitList, ShouldHaveLength, 2)
+				So(domain.requests, ShouldResemble, []string{"GET /", "GET /123_aggregate/_search"})
+			})
+		})
+	})
+}
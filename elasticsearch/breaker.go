@@ -0,0 +1,169 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+)
+
+// States of a circuit breaker
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// CircuitBreaker stops calls to elasticsearch for a cooldown period once it is failing, either after a run of
+// consecutive failures or when the error rate across the most recent calls is too high. Once the cooldown has
+// passed a single trial call is let through, closing the breaker if it succeeds and opening it again if not.
+type CircuitBreaker struct {
+	maxConsecutiveFailures int
+	maxErrorRate           float64
+	window                 int
+	cooldown               time.Duration
+	now                    func() time.Time
+
+	mu                  sync.Mutex
+	state               string
+	consecutiveFailures int
+	outcomes            []bool // most recent call outcomes, true for a failure
+	next                int
+	openedAt            time.Time
+	trialInFlight       bool
+}
+
+// NewCircuitBreaker creates a closed circuit breaker which opens after maxConsecutiveFailures consecutive failures,
+// or once more than maxErrorRate of the last window calls have failed. Either rule is disabled when set to 0.
+func NewCircuitBreaker(maxConsecutiveFailures int, maxErrorRate float64, window int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		maxConsecutiveFailures: maxConsecutiveFailures,
+		maxErrorRate:           maxErrorRate,
+		window:                 window,
+		cooldown:               cooldown,
+		now:                    time.Now,
+		state:                  BreakerClosed,
+	}
+}
+
+// Allow returns ErrElasticsearchUnavailable if a call to elasticsearch should not be made at the moment, along with
+// how long until one might be. While a trial call is in flight that is unknown, so a second is waited for its outcome.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if elapsed := b.now().Sub(b.openedAt); elapsed < b.cooldown {
+			return errs.WithRetryAfter(errs.ErrElasticsearchUnavailable, b.cooldown-elapsed)
+		}
+		b.state = BreakerHalfOpen
+		b.trialInFlight = true
+		return nil
+	case BreakerHalfOpen:
+		if b.trialInFlight {
+			return errs.WithRetryAfter(errs.ErrElasticsearchUnavailable, time.Second)
+		}
+		b.trialInFlight = true
+		return nil
+	}
+
+	return nil
+}
+
+// Record records the outcome of a call to elasticsearch which was allowed
+func (b *CircuitBreaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.trialInFlight = false
+		if failed {
+			b.open()
+		} else {
+			b.close()
+		}
+		return
+	}
+
+	if b.state == BreakerOpen {
+		return
+	}
+
+	if failed {
+		b.consecutiveFailures++
+	} else {
+		b.consecutiveFailures = 0
+	}
+
+	if b.window > 0 {
+		if len(b.outcomes) < b.window {
+			b.outcomes = append(b.outcomes, failed)
+		} else {
+			b.outcomes[b.next] = failed
+			b.next = (b.next + 1) % b.window
+		}
+	}
+
+	if b.maxConsecutiveFailures > 0 && b.consecutiveFailures >= b.maxConsecutiveFailures {
+		b.open()
+		return
+	}
+
+	if b.maxErrorRate > 0 && b.window > 0 && len(b.outcomes) == b.window && b.errorRate() > b.maxErrorRate {
+		b.open()
+	}
+}
+
+// Release releases a call which was allowed, without recording its outcome, so that another trial call can be made
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.trialInFlight = false
+	}
+}
+
+// State returns whether the breaker is closed, open or half-open
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Checker reports the state of the breaker, warning while calls to elasticsearch are being stopped
+func (b *CircuitBreaker) Checker(_ context.Context, state *healthcheck.CheckState) error {
+	switch current := b.State(); current {
+	case BreakerClosed:
+		return state.Update(healthcheck.StatusOK, "circuit breaker is closed", http.StatusOK)
+	default:
+		return state.Update(healthcheck.StatusWarning, fmt.Sprintf("circuit breaker is %s, calls to elasticsearch are being stopped", current), http.StatusServiceUnavailable)
+	}
+}
+
+func (b *CircuitBreaker) errorRate() float64 {
+	failures := 0
+	for _, failed := range b.outcomes {
+		if failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(b.outcomes))
+}
+
+func (b *CircuitBreaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = b.now()
+}
+
+func (b *CircuitBreaker) close() {
+	b.state = BreakerClosed
+	b.consecutiveFailures = 0
+	b.outcomes = b.outcomes[:0]
+	b.next = 0
+}
@@ -0,0 +1,158 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newTestBreaker(maxConsecutiveFailures int, maxErrorRate float64, window int) (*CircuitBreaker, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker(maxConsecutiveFailures, maxErrorRate, window, 30*time.Second)
+	breaker.now = func() time.Time { return now }
+	return breaker, &now
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	Convey("Given a breaker which opens after 3 consecutive failures", t, func() {
+		breaker, now := newTestBreaker(3, 0, 0)
+
+		Convey("When fewer than 3 calls fail in a row", func() {
+			breaker.Record(true)
+			breaker.Record(true)
+			breaker.Record(false)
+			breaker.Record(true)
+
+			Convey("Then the breaker stays closed", func() {
+				So(breaker.State(), ShouldEqual, BreakerClosed)
+				So(breaker.Allow(), ShouldBeNil)
+			})
+		})
+
+		Convey("When 3 calls fail in a row", func() {
+			breaker.Record(true)
+			breaker.Record(true)
+			breaker.Record(true)
+
+			Convey("Then the breaker opens and calls are stopped", func() {
+				So(breaker.State(), ShouldEqual, BreakerOpen)
+				So(errors.Is(breaker.Allow(), errs.ErrElasticsearchUnavailable), ShouldBeTrue)
+			})
+
+			Convey("Then calls can be retried once the rest of the cooldown has passed", func() {
+				*now = now.Add(10 * time.Second)
+				var retry *errs.RetryAfterError
+				So(errors.As(breaker.Allow(), &retry), ShouldBeTrue)
+				So(retry.After, ShouldEqual, 20*time.Second)
+			})
+
+			Convey("Then once the cooldown has passed a single trial call is allowed", func() {
+				*now = now.Add(30 * time.Second)
+				So(breaker.Allow(), ShouldBeNil)
+				So(breaker.State(), ShouldEqual, BreakerHalfOpen)
+				So(errors.Is(breaker.Allow(), errs.ErrElasticsearchUnavailable), ShouldBeTrue)
+
+				Convey("And the breaker closes if the trial call succeeds", func() {
+					breaker.Record(false)
+					So(breaker.State(), ShouldEqual, BreakerClosed)
+					So(breaker.Allow(), ShouldBeNil)
+				})
+
+				Convey("And the breaker opens again if the trial call fails", func() {
+					breaker.Record(true)
+					So(breaker.State(), ShouldEqual, BreakerOpen)
+					So(errors.Is(breaker.Allow(), errs.ErrElasticsearchUnavailable), ShouldBeTrue)
+				})
+
+				Convey("And another trial call is allowed if the trial call is released", func() {
+					breaker.Release()
+					So(breaker.State(), ShouldEqual, BreakerHalfOpen)
+					So(breaker.Allow(), ShouldBeNil)
+				})
+			})
+		})
+	})
+
+	Convey("Given a breaker which opens when more than half of the last 4 calls fail", t, func() {
+		breaker, _ := newTestBreaker(0, 0.5, 4)
+
+		Convey("When half of the last 4 calls fail", func() {
+			breaker.Record(false)
+			breaker.Record(true)
+			breaker.Record(false)
+			breaker.Record(true)
+
+			Convey("Then the breaker stays closed", func() {
+				So(breaker.State(), ShouldEqual, BreakerClosed)
+			})
+
+			Convey("Then the breaker opens once the oldest success is replaced by a failure", func() {
+				breaker.Record(true)
+				So(breaker.State(), ShouldEqual, BreakerOpen)
+			})
+		})
+
+		Convey("When fewer calls than the window have been made", func() {
+			breaker.Record(true)
+			breaker.Record(true)
+
+			Convey("Then the breaker stays closed", func() {
+				So(breaker.State(), ShouldEqual, BreakerClosed)
+			})
+		})
+	})
+
+	Convey("Given a closed breaker", t, func() {
+		breaker, _ := newTestBreaker(1, 0, 0)
+		state := healthcheck.NewCheckState("Elasticsearch Circuit Breaker")
+
+		Convey("Then the health check is ok", func() {
+			So(breaker.Checker(context.Background(), state), ShouldBeNil)
+			So(state.Status(), ShouldEqual, healthcheck.StatusOK)
+		})
+
+		Convey("When the breaker opens then the health check warns", func() {
+			breaker.Record(true)
+			So(breaker.Checker(context.Background(), state), ShouldBeNil)
+			So(state.Status(), ShouldEqual, healthcheck.StatusWarning)
+			So(state.Message(), ShouldContainSubstring, BreakerOpen)
+		})
+	})
+}
+
+func TestCallElasticThroughBreaker(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given elasticsearch is failing and the breaker opens after 2 consecutive failures", t, func() {
+		flaky := &flakyServer{statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}}
+		server := httptest.NewServer(flaky)
+		defer server.Close()
+
+		breaker, _ := newTestBreaker(2, 0, 0)
+		api := newRetryingAPI(server.URL, 3, 0)
+		api.endpoints[0].breaker = breaker
+
+		Convey("When an idempotent call is made", func() {
+			_, _, err := api.CallElastic(ctx, server.URL+"/index/_search", http.MethodGet, nil)
+
+			Convey("Then retries stop once the breaker opens", func() {
+				So(errors.Is(err, errs.ErrElasticsearchUnavailable), ShouldBeTrue)
+				So(flaky.attempts(), ShouldEqual, 2)
+				So(breaker.State(), ShouldEqual, BreakerOpen)
+			})
+
+			Convey("Then further searches fail fast without calling elasticsearch", func() {
+				_, _, err = api.QuerySearchIndex(ctx, "123", "aggregate", "term", 10, 0, false)
+				So(errors.Is(err, errs.ErrElasticsearchUnavailable), ShouldBeTrue)
+				So(flaky.attempts(), ShouldEqual, 2)
+			})
+		})
+	})
+}
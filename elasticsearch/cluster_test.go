@@ -0,0 +1,252 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	dphttp "github.com/ONSdigital/dp-net/http"
+)
+
+// fakeCluster is a stand-in for the parts of the elasticsearch API used to manage search indexes
+type fakeCluster struct {
+	mu        sync.Mutex
+	indexes   map[string]int64           // index name to creation date
+	aliases   map[string]string          // alias to index
+	mappings  map[string]json.RawMessage // index name to mapping
+	templates map[string]json.RawMessage // template name to template
+	options   []models.SearchResult      // documents every search matches, all equally relevant
+	searches  []json.RawMessage          // bodies of searches, in the order made
+	info      string                     // response from the root of the cluster, reporting its version
+	requests  []string
+}
+
+func newFakeCluster() *fakeCluster {
+	return &fakeCluster{
+		info:      es7Info,
+		indexes:   make(map[string]int64),
+		aliases:   make(map[string]string),
+		mappings:  make(map[string]json.RawMessage),
+		templates: make(map[string]json.RawMessage),
+	}
+}
+
+func (c *fakeCluster) addIndex(name string, created int64) {
+	c.indexes[name] = created
+}
+
+// newAPI returns an API which calls the fake cluster, the server should be closed once finished with
+func (c *fakeCluster) newAPI() (*API, *httptest.Server) {
+	server := httptest.NewServer(c)
+	return NewElasticSearchAPI(dphttp.NewClient(), []*Endpoint{NewEndpoint(server.URL, nil)}, ReadModeFailover, BackendElasticsearch, false, nil, "es", "eu-west-1", 0, 0, nil), server
+}
+
+func (c *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests = append(c.requests, r.Method+" "+r.URL.Path)
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/":
+		_, _ = w.Write([]byte(c.info))
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/_cat/indices/"):
+		c.catIndices(w, strings.TrimPrefix(r.URL.Path, "/_cat/indices/"))
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/_cat/aliases/"):
+		c.catAliases(w, strings.TrimPrefix(r.URL.Path, "/_cat/aliases/"))
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/_alias/"):
+		c.getAlias(w, strings.TrimPrefix(r.URL.Path, "/_alias/"))
+	case r.Method == http.MethodPost && r.URL.Path == "/_aliases":
+		c.updateAliases(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/_template/"):
+		c.getTemplate(w, strings.TrimPrefix(r.URL.Path, "/_template/"))
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/_template/"):
+		body, _ := io.ReadAll(r.Body)
+		c.templates[strings.TrimPrefix(r.URL.Path, "/_template/")] = body
+		writeJSON(w, map[string]bool{"acknowledged": true})
+	case strings.HasSuffix(r.URL.Path, "/_search"):
+		body, _ := io.ReadAll(r.Body)
+		c.searches = append(c.searches, body)
+		c.search(w, body)
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/_mapping"):
+		c.getMappings(w, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/_mapping"))
+	case r.Method == http.MethodDelete:
+		c.deleteIndexes(w, strings.Split(strings.TrimPrefix(r.URL.Path, "/"), ","))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (c *fakeCluster) catIndices(w http.ResponseWriter, pattern string) {
+	if target, ok := c.aliases[pattern]; ok {
+		pattern = target
+	}
+
+	result := []map[string]string{}
+	for _, name := range c.sortedIndexes() {
+		if matched, _ := path.Match(pattern, name); matched {
+			result = append(result, map[string]string{"index": name, "creation.date": strconv.FormatInt(c.indexes[name], 10)})
+		}
+	}
+
+	if len(result) == 0 && !strings.Contains(pattern, "*") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeJSON(w, result)
+}
+
+func (c *fakeCluster) catAliases(w http.ResponseWriter, pattern string) {
+	result := []map[string]string{}
+	for alias, index := range c.aliases {
+		if matched, _ := path.Match(pattern, alias); matched {
+			result = append(result, map[string]string{"alias": alias, "index": index})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i]["alias"] < result[j]["alias"] })
+	writeJSON(w, result)
+}
+
+func (c *fakeCluster) getAlias(w http.ResponseWriter, alias string) {
+	index, ok := c.aliases[alias]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]interface{}{index: map[string]interface{}{"aliases": map[string]interface{}{alias: map[string]string{}}}})
+}
+
+func (c *fakeCluster) updateAliases(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	actions := aliasActions{}
+	if err := json.Unmarshal(body, &actions); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	for _, action := range actions.Actions {
+		switch {
+		case action.Remove != nil:
+			delete(c.aliases, action.Remove.Alias)
+		case action.RemoveIndex != nil:
+			delete(c.indexes, action.RemoveIndex.Index)
+		case action.Add != nil:
+			c.aliases[action.Add.Alias] = action.Add.Index
+		}
+	}
+	writeJSON(w, map[string]bool{"acknowledged": true})
+}
+
+func (c *fakeCluster) deleteIndexes(w http.ResponseWriter, names []string) {
+	for _, name := range names {
+		if _, ok := c.indexes[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+
+	for _, name := range names {
+		delete(c.indexes, name)
+		for alias, index := range c.aliases {
+			if index == name {
+				delete(c.aliases, alias)
+			}
+		}
+	}
+	writeJSON(w, map[string]bool{"acknowledged": true})
+}
+
+// search returns a page of the options, which are equally relevant. Like a cluster merging the results of
+// several shards, options which the requested sort does not order are returned in a different order by
+// every search. Labels are sorted as the index template's sortable normalizer sorts them, ignoring case.
+func (c *fakeCluster) search(w http.ResponseWriter, body []byte) {
+	var search struct {
+		From int                                 `json:"from"`
+		Size int                                 `json:"size"`
+		Sort []map[string]struct{ Order string } `json:"sort"`
+	}
+	if err := json.Unmarshal(body, &search); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	options := make([]models.SearchResult, len(c.options))
+	copy(options, c.options)
+	rand.New(rand.NewSource(int64(len(c.searches)))).Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+
+	sort.SliceStable(options, func(i, j int) bool {
+		for _, s := range search.Sort {
+			for field, order := range s {
+				a, b := sortValue(options[i], field), sortValue(options[j], field)
+				if a != b {
+					return (a < b) == (order.Order == "asc")
+				}
+			}
+		}
+		return false
+	})
+
+	hits := []models.HitList{}
+	for i := search.From; i < search.From+search.Size && i < len(options); i++ {
+		hits = append(hits, models.HitList{Score: 1, Source: options[i]})
+	}
+	writeJSON(w, models.SearchResponse{Hits: models.Hits{Total: models.Total{Value: len(options), Relation: models.TotalCountExact}, HitList: hits}})
+}
+
+// sortValue returns the value of option sorted on by field, the relevance of every option is the same
+func sortValue(option models.SearchResult, field string) string {
+	switch field {
+	case "label.keyword":
+		return strings.ToLower(option.Label)
+	case "code.keyword":
+		return option.Code
+	}
+	return ""
+}
+
+func (c *fakeCluster) getTemplate(w http.ResponseWriter, name string) {
+	template, ok := c.templates[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]json.RawMessage{name: template})
+}
+
+func (c *fakeCluster) getMappings(w http.ResponseWriter, pattern string) {
+	result := map[string]interface{}{}
+	for _, name := range c.sortedIndexes() {
+		if matched, _ := path.Match(pattern, name); matched {
+			m, ok := c.mappings[name]
+			if !ok {
+				m = json.RawMessage(`{}`)
+			}
+			result[name] = map[string]json.RawMessage{"mappings": m}
+		}
+	}
+	writeJSON(w, result)
+}
+
+func (c *fakeCluster) sortedIndexes() []string {
+	names := make([]string, 0, len(c.indexes))
+	for name := range c.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	b, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
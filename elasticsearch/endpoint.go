@@ -0,0 +1,106 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+)
+
+// Modes of choosing which cluster to read searches from
+const (
+	// ReadModeFailover reads from the first healthy cluster, in the order they are configured
+	ReadModeFailover = "failover"
+
+	// ReadModeRoundRobin spreads reads across the healthy clusters in turn
+	ReadModeRoundRobin = "round-robin"
+)
+
+// ErrUnsupportedReadMode is returned when the read mode is not failover or round-robin
+var ErrUnsupportedReadMode = errors.New("unsupported read mode, must be failover or round-robin")
+
+// ValidateReadMode returns ErrUnsupportedReadMode if mode is not a mode of choosing which cluster to read from
+func ValidateReadMode(mode string) error {
+	switch mode {
+	case ReadModeFailover, ReadModeRoundRobin:
+		return nil
+	}
+	return ErrUnsupportedReadMode
+}
+
+// Endpoint is a cluster which searches can be read from, each with its own circuit breaker. The first endpoint
+// is the primary, which every call other than a search is made to.
+type Endpoint struct {
+	URL     string
+	breaker *CircuitBreaker
+	healthy atomic.Bool
+
+	versionMu        sync.Mutex
+	version          *ClusterVersion
+	codec            Codec
+	versionStale     bool
+	lastVersionProbe time.Time
+}
+
+// NewEndpoint creates an endpoint for the cluster at url, which is healthy until it fails. Calls to the cluster
+// are stopped while breaker, if any, is open.
+func NewEndpoint(url string, breaker *CircuitBreaker) *Endpoint {
+	endpoint := &Endpoint{URL: url, breaker: breaker}
+	endpoint.healthy.Store(true)
+	return endpoint
+}
+
+// Breaker returns the circuit breaker calls to the cluster are made through, if any
+func (e *Endpoint) Breaker() *CircuitBreaker {
+	return e.breaker
+}
+
+// Healthy reports whether the cluster handled the last search, or passed the last health check, made against it
+func (e *Endpoint) Healthy() bool {
+	return e.healthy.Load()
+}
+
+// Checker returns a health check for the cluster made by check, the result of which is taken into account
+// when choosing the cluster to read from
+func (e *Endpoint) Checker(check healthcheck.Checker) healthcheck.Checker {
+	return func(ctx context.Context, state *healthcheck.CheckState) error {
+		err := check(ctx, state)
+		e.healthy.Store(err == nil && state.Status() != healthcheck.StatusCritical)
+		return err
+	}
+}
+
+// readEndpoints returns the endpoints to try a search against, in order. Healthy endpoints are tried first,
+// either in the order they are configured or, for round-robin reads, starting from the next in turn. Unhealthy
+// endpoints are tried last, in case they have recovered.
+func (api *API) readEndpoints() []*Endpoint {
+	healthy := make([]*Endpoint, 0, len(api.endpoints))
+	unhealthy := make([]*Endpoint, 0, len(api.endpoints))
+	for _, endpoint := range api.endpoints {
+		if endpoint.Healthy() {
+			healthy = append(healthy, endpoint)
+		} else {
+			unhealthy = append(unhealthy, endpoint)
+		}
+	}
+
+	ordered := make([]*Endpoint, 0, len(api.endpoints))
+	start := 0
+	if api.readMode == ReadModeRoundRobin && len(healthy) > 1 {
+		start = int((api.nextRead.Add(1) - 1) % uint64(len(healthy)))
+	}
+	ordered = append(ordered, healthy[start:]...)
+	ordered = append(ordered, healthy[:start]...)
+
+	return append(ordered, unhealthy...)
+}
+
+// canFailOver reports whether a search which failed against one cluster should be tried against another
+func canFailOver(status int, err error) bool {
+	return errors.Is(err, errs.ErrElasticsearchUnavailable) || status == 0 || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
@@ -0,0 +1,224 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const es7Info = `{"name":"node-1","version":{"number":"7.10.2"},"tagline":"You Know, for Search"}`
+
+// readCluster is an elasticsearch 7 cluster which responds to searches with status, if set, counting the searches made
+type readCluster struct {
+	mu       sync.Mutex
+	status   int
+	searches int
+}
+
+func (c *readCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		w.Write([]byte(es7Info))
+		return
+	}
+
+	c.mu.Lock()
+	c.searches++
+	status := c.status
+	c.mu.Unlock()
+
+	if status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+	w.Write([]byte(es7SearchResponse))
+}
+
+func (c *readCluster) searchCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.searches
+}
+
+func newReadAPI(readMode string, servers ...*httptest.Server) *API {
+	api := newRetryingAPI(servers[0].URL, 0, 0)
+	api.readMode = readMode
+	api.endpoints = nil
+	for _, server := range servers {
+		api.endpoints = append(api.endpoints, NewEndpoint(server.URL, nil))
+	}
+	return api
+}
+
+func TestReadFailover(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a primary and a secondary cluster read from with failover", t, func() {
+		primary, secondary := &readCluster{}, &readCluster{}
+		primaryServer, secondaryServer := httptest.NewServer(primary), httptest.NewServer(secondary)
+		defer primaryServer.Close()
+		defer secondaryServer.Close()
+
+		api := newReadAPI(ReadModeFailover, primaryServer, secondaryServer)
+
+		Convey("When both are healthy", func() {
+			for i := 0; i < 3; i++ {
+				_, _, err := api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+				So(err, ShouldBeNil)
+			}
+
+			Convey("Then every search is read from the primary", func() {
+				So(primary.searchCount(), ShouldEqual, 3)
+				So(secondary.searchCount(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the primary is unavailable", func() {
+			primary.status = http.StatusServiceUnavailable
+			response, _, err := api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+
+			Convey("Then the search is read from the secondary", func() {
+				So(err, ShouldBeNil)
+				So(response.Hits.Total.Value, ShouldEqual, 2)
+				So(primary.searchCount(), ShouldEqual, 1)
+				So(secondary.searchCount(), ShouldEqual, 1)
+				So(api.endpoints[0].Healthy(), ShouldBeFalse)
+			})
+
+			Convey("Then further searches are read from the secondary first", func() {
+				_, _, err = api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+				So(err, ShouldBeNil)
+				So(primary.searchCount(), ShouldEqual, 1)
+				So(secondary.searchCount(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When both are unavailable", func() {
+			primary.status = http.StatusServiceUnavailable
+			secondary.status = http.StatusBadGateway
+			_, status, err := api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+
+			Convey("Then the search fails with the status of the last cluster tried", func() {
+				So(err, ShouldEqual, errs.ErrUnexpectedStatusCode)
+				So(status, ShouldEqual, http.StatusBadGateway)
+				So(api.endpoints[0].Healthy(), ShouldBeFalse)
+				So(api.endpoints[1].Healthy(), ShouldBeFalse)
+			})
+		})
+
+		Convey("When the index is missing from the primary", func() {
+			primary.status = http.StatusNotFound
+			_, status, err := api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+
+			Convey("Then the search is not read from the secondary, as the dimension is not searchable", func() {
+				So(err, ShouldEqual, errs.ErrDimensionNotSearchable)
+				So(status, ShouldEqual, http.StatusNotFound)
+				So(secondary.searchCount(), ShouldEqual, 0)
+				So(api.endpoints[0].Healthy(), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestReadFailoverAcrossVersions(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given an elasticsearch 7 primary and an elasticsearch 6 secondary read from with failover", t, func() {
+		primary := &readCluster{}
+		secondary := &versionedCluster{
+			info:           `{"name":"node-1","version":{"number":"6.8.23"},"tagline":"You Know, for Search"}`,
+			searchResponse: es6SearchResponse,
+		}
+		primaryServer, secondaryServer := httptest.NewServer(primary), httptest.NewServer(secondary)
+		defer primaryServer.Close()
+		defer secondaryServer.Close()
+
+		api := newReadAPI(ReadModeFailover, primaryServer, secondaryServer)
+		_, err := api.DetectVersion(ctx)
+		So(err, ShouldBeNil)
+
+		Convey("When the primary is unavailable", func() {
+			primary.status = http.StatusServiceUnavailable
+			response, _, err := api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+
+			Convey("Then the version of the secondary is detected and its search decoded with the es6 codec", func() {
+				So(err, ShouldBeNil)
+				So(response.Hits.Total.Value, ShouldEqual, 2)
+				So(secondary.versionProbes, ShouldEqual, 1)
+				So(api.endpoints[1].codec.Name(), ShouldEqual, CodecES6)
+				So(api.Version().String(), ShouldEqual, "elasticsearch 7.10.2")
+			})
+		})
+	})
+}
+
+func TestReadRoundRobin(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given two clusters read from in turn", t, func() {
+		first, second := &readCluster{}, &readCluster{}
+		firstServer, secondServer := httptest.NewServer(first), httptest.NewServer(second)
+		defer firstServer.Close()
+		defer secondServer.Close()
+
+		api := newReadAPI(ReadModeRoundRobin, firstServer, secondServer)
+
+		Convey("When several searches are made", func() {
+			for i := 0; i < 4; i++ {
+				_, _, err := api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+				So(err, ShouldBeNil)
+			}
+
+			Convey("Then they are spread across both clusters", func() {
+				So(first.searchCount(), ShouldEqual, 2)
+				So(second.searchCount(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When one cluster fails its health check", func() {
+			state := healthcheck.NewCheckState("Elasticsearch 2")
+			check := api.endpoints[1].Checker(func(ctx context.Context, state *healthcheck.CheckState) error {
+				return state.Update(healthcheck.StatusCritical, "cluster is red", 0)
+			})
+			So(check(ctx, state), ShouldBeNil)
+
+			for i := 0; i < 4; i++ {
+				_, _, err := api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+				So(err, ShouldBeNil)
+			}
+
+			Convey("Then searches are only read from the healthy cluster", func() {
+				So(api.endpoints[1].Healthy(), ShouldBeFalse)
+				So(first.searchCount(), ShouldEqual, 4)
+				So(second.searchCount(), ShouldEqual, 0)
+			})
+
+			Convey("Then the cluster is read from again once it passes its health check", func() {
+				check = api.endpoints[1].Checker(func(ctx context.Context, state *healthcheck.CheckState) error {
+					return state.Update(healthcheck.StatusOK, "cluster is green", http.StatusOK)
+				})
+				So(check(ctx, state), ShouldBeNil)
+				So(api.endpoints[1].Healthy(), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestValidateReadMode(t *testing.T) {
+	Convey("Given the supported read modes", t, func() {
+		Convey("Then they are valid", func() {
+			So(ValidateReadMode(ReadModeFailover), ShouldBeNil)
+			So(ValidateReadMode(ReadModeRoundRobin), ShouldBeNil)
+		})
+
+		Convey("Then any other mode is not", func() {
+			So(errors.Is(ValidateReadMode("random"), ErrUnsupportedReadMode), ShouldBeTrue)
+		})
+	})
+}
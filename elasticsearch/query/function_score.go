@@ -0,0 +1,200 @@
+package query
+
+import "encoding/json"
+
+// Function adjusts the score of the documents matched by a function_score clause
+type Function interface {
+	json.Marshaler
+	isFunction()
+}
+
+// FunctionScoreClause adjusts the scores of the documents matched by another clause
+type FunctionScoreClause struct {
+	query     Clause
+	functions []Function
+	scoreMode string
+	boostMode string
+	maxBoost  float64
+}
+
+// FunctionScore returns a clause adjusting the scores of the documents matched by query
+func FunctionScore(query Clause) *FunctionScoreClause {
+	return &FunctionScoreClause{query: query}
+}
+
+// Add adds functions which adjust the score
+func (c *FunctionScoreClause) Add(functions ...Function) *FunctionScoreClause {
+	c.functions = append(c.functions, functions...)
+	return c
+}
+
+// ScoreMode is how the scores of the functions are combined, e.g. multiply, sum or max
+func (c *FunctionScoreClause) ScoreMode(mode string) *FunctionScoreClause {
+	c.scoreMode = mode
+	return c
+}
+
+// BoostMode is how the combined score of the functions is combined with the score of the query, e.g. multiply or sum
+func (c *FunctionScoreClause) BoostMode(mode string) *FunctionScoreClause {
+	c.boostMode = mode
+	return c
+}
+
+// MaxBoost caps the combined score of the functions
+func (c *FunctionScoreClause) MaxBoost(maxBoost float64) *FunctionScoreClause {
+	c.maxBoost = maxBoost
+	return c
+}
+
+func (*FunctionScoreClause) isClause() {}
+
+// MarshalJSON marshals the clause as a function_score query
+func (c *FunctionScoreClause) MarshalJSON() ([]byte, error) {
+	options := object{}
+	setIf(options, "query", c.query, c.query != nil)
+	setIf(options, "functions", c.functions, len(c.functions) > 0)
+	setIf(options, "score_mode", c.scoreMode, c.scoreMode != "")
+	setIf(options, "boost_mode", c.boostMode, c.boostMode != "")
+	setIf(options, "max_boost", c.maxBoost, c.maxBoost != 0)
+
+	return json.Marshal(object{"function_score": options})
+}
+
+// WeightFunction multiplies the score of documents matching a filter by a weight
+type WeightFunction struct {
+	weight float64
+	filter Clause
+}
+
+// Weight returns a function multiplying the score of documents matching filter, or every document if
+// filter is nil, by weight
+func Weight(weight float64, filter Clause) *WeightFunction {
+	return &WeightFunction{weight: weight, filter: filter}
+}
+
+func (*WeightFunction) isFunction() {}
+
+// MarshalJSON marshals the function as a weight
+func (f *WeightFunction) MarshalJSON() ([]byte, error) {
+	options := object{"weight": f.weight}
+	setIf(options, "filter", f.filter, f.filter != nil)
+	return json.Marshal(options)
+}
+
+// FieldValueFactorFunction scores documents by the value of a numeric field
+type FieldValueFactorFunction struct {
+	field    string
+	factor   float64
+	modifier string
+	missing  *float64
+	filter   Clause
+}
+
+// FieldValueFactor returns a function scoring documents by the value of field
+func FieldValueFactor(field string) *FieldValueFactorFunction {
+	return &FieldValueFactorFunction{field: field}
+}
+
+// Factor multiplies the value of the field
+func (f *FieldValueFactorFunction) Factor(factor float64) *FieldValueFactorFunction {
+	f.factor = factor
+	return f
+}
+
+// Modifier is applied to the value of the field, e.g. log1p or sqrt
+func (f *FieldValueFactorFunction) Modifier(modifier string) *FieldValueFactorFunction {
+	f.modifier = modifier
+	return f
+}
+
+// Missing is used as the value of the field for documents without one
+func (f *FieldValueFactorFunction) Missing(missing float64) *FieldValueFactorFunction {
+	f.missing = &missing
+	return f
+}
+
+// Filter restricts the function to documents matching filter
+func (f *FieldValueFactorFunction) Filter(filter Clause) *FieldValueFactorFunction {
+	f.filter = filter
+	return f
+}
+
+func (*FieldValueFactorFunction) isFunction() {}
+
+// MarshalJSON marshals the function as a field_value_factor
+func (f *FieldValueFactorFunction) MarshalJSON() ([]byte, error) {
+	options := object{"field": f.field}
+	setIf(options, "factor", f.factor, f.factor != 0)
+	setIf(options, "modifier", f.modifier, f.modifier != "")
+	if f.missing != nil {
+		options["missing"] = *f.missing
+	}
+
+	function := object{"field_value_factor": options}
+	setIf(function, "filter", f.filter, f.filter != nil)
+	return json.Marshal(function)
+}
+
+// Kinds of decay function, by the shape of the curve the score decays along
+const (
+	DecayExp    = "exp"
+	DecayGauss  = "gauss"
+	DecayLinear = "linear"
+)
+
+// DecayFunction scores documents by how far the value of a numeric field is from an origin, documents
+// without a value are given a score of 1
+type DecayFunction struct {
+	kind   string
+	field  string
+	origin interface{}
+	scale  interface{}
+	offset interface{}
+	decay  float64
+	weight float64
+	filter Clause
+}
+
+// Decay returns a function of kind decaying the score of documents as the value of field moves away from
+// origin, to half at scale from it unless the decay is set
+func Decay(kind, field string, origin, scale interface{}) *DecayFunction {
+	return &DecayFunction{kind: kind, field: field, origin: origin, scale: scale}
+}
+
+// Offset is the distance from the origin within which the score does not decay
+func (f *DecayFunction) Offset(offset interface{}) *DecayFunction {
+	f.offset = offset
+	return f
+}
+
+// Decay is the score of documents at scale from the origin
+func (f *DecayFunction) Decay(decay float64) *DecayFunction {
+	f.decay = decay
+	return f
+}
+
+// Weight multiplies the score of the function
+func (f *DecayFunction) Weight(weight float64) *DecayFunction {
+	f.weight = weight
+	return f
+}
+
+// Filter restricts the function to documents matching filter
+func (f *DecayFunction) Filter(filter Clause) *DecayFunction {
+	f.filter = filter
+	return f
+}
+
+func (*DecayFunction) isFunction() {}
+
+// MarshalJSON marshals the function as a decay function of its kind
+func (f *DecayFunction) MarshalJSON() ([]byte, error) {
+	options := object{"origin": f.origin, "scale": f.scale}
+	setIf(options, "offset", f.offset, f.offset != nil)
+	setIf(options, "decay", f.decay, f.decay != 0)
+
+	function := object{f.kind: object{f.field: options}}
+	setIf(function, "weight", f.weight, f.weight != 0)
+	setIf(function, "filter", f.filter, f.filter != nil)
+	return json.Marshal(function)
+}
@@ -0,0 +1,308 @@
+// Package query builds the elasticsearch query DSL, so that request bodies are composed from typed
+// clauses rather than hand-written maps
+package query
+
+import "encoding/json"
+
+// Clause is a query clause, marshalled as the object elasticsearch expects
+type Clause interface {
+	json.Marshaler
+	isClause()
+}
+
+// MatchClause matches analysed text in a single field
+type MatchClause struct {
+	field     string
+	query     string
+	boost     float64
+	operator  string
+	fuzziness string
+}
+
+// Match returns a clause matching query in field
+func Match(field, query string) *MatchClause {
+	return &MatchClause{field: field, query: query}
+}
+
+// Boost multiplies the score of documents which match
+func (c *MatchClause) Boost(boost float64) *MatchClause {
+	c.boost = boost
+	return c
+}
+
+// Operator is whether any (or) or all (and) of the terms in the query must match
+func (c *MatchClause) Operator(operator string) *MatchClause {
+	c.operator = operator
+	return c
+}
+
+// Fuzziness is the edit distance allowed when matching terms, e.g. AUTO
+func (c *MatchClause) Fuzziness(fuzziness string) *MatchClause {
+	c.fuzziness = fuzziness
+	return c
+}
+
+func (*MatchClause) isClause() {}
+
+// MarshalJSON uses the short form of the clause unless options have been set
+func (c *MatchClause) MarshalJSON() ([]byte, error) {
+	if c.boost == 0 && c.operator == "" && c.fuzziness == "" {
+		return json.Marshal(object{"match": object{c.field: c.query}})
+	}
+
+	options := object{"query": c.query}
+	setIf(options, "boost", c.boost, c.boost != 0)
+	setIf(options, "operator", c.operator, c.operator != "")
+	setIf(options, "fuzziness", c.fuzziness, c.fuzziness != "")
+
+	return json.Marshal(object{"match": object{c.field: options}})
+}
+
+// MultiMatchClause matches analysed text across several fields
+type MultiMatchClause struct {
+	query     string
+	fields    []string
+	matchType string
+	boost     float64
+	operator  string
+	fuzziness string
+}
+
+// MultiMatch returns a clause matching query in any of fields. A field may be boosted with a suffix, e.g. label^2.
+func MultiMatch(query string, fields ...string) *MultiMatchClause {
+	return &MultiMatchClause{query: query, fields: fields}
+}
+
+// Type is how the fields are combined to score a document, e.g. best_fields or cross_fields
+func (c *MultiMatchClause) Type(matchType string) *MultiMatchClause {
+	c.matchType = matchType
+	return c
+}
+
+// Boost multiplies the score of documents which match
+func (c *MultiMatchClause) Boost(boost float64) *MultiMatchClause {
+	c.boost = boost
+	return c
+}
+
+// Operator is whether any (or) or all (and) of the terms in the query must match
+func (c *MultiMatchClause) Operator(operator string) *MultiMatchClause {
+	c.operator = operator
+	return c
+}
+
+// Fuzziness is the edit distance allowed when matching terms, e.g. AUTO
+func (c *MultiMatchClause) Fuzziness(fuzziness string) *MultiMatchClause {
+	c.fuzziness = fuzziness
+	return c
+}
+
+func (*MultiMatchClause) isClause() {}
+
+// MarshalJSON marshals the clause as a multi_match query
+func (c *MultiMatchClause) MarshalJSON() ([]byte, error) {
+	options := object{"query": c.query, "fields": c.fields}
+	setIf(options, "type", c.matchType, c.matchType != "")
+	setIf(options, "boost", c.boost, c.boost != 0)
+	setIf(options, "operator", c.operator, c.operator != "")
+	setIf(options, "fuzziness", c.fuzziness, c.fuzziness != "")
+
+	return json.Marshal(object{"multi_match": options})
+}
+
+// TermClause matches an exact value in a field which is not analysed
+type TermClause struct {
+	field string
+	value interface{}
+	boost float64
+}
+
+// Term returns a clause matching value exactly in field
+func Term(field string, value interface{}) *TermClause {
+	return &TermClause{field: field, value: value}
+}
+
+// Boost multiplies the score of documents which match
+func (c *TermClause) Boost(boost float64) *TermClause {
+	c.boost = boost
+	return c
+}
+
+func (*TermClause) isClause() {}
+
+// MarshalJSON uses the short form of the clause unless it has been boosted
+func (c *TermClause) MarshalJSON() ([]byte, error) {
+	if c.boost == 0 {
+		return json.Marshal(object{"term": object{c.field: c.value}})
+	}
+	return json.Marshal(object{"term": object{c.field: object{"value": c.value, "boost": c.boost}}})
+}
+
+// TermsClause matches any of several exact values in a field which is not analysed
+type TermsClause struct {
+	field  string
+	values []interface{}
+}
+
+// Terms returns a clause matching any of values exactly in field
+func Terms(field string, values ...interface{}) *TermsClause {
+	return &TermsClause{field: field, values: values}
+}
+
+func (*TermsClause) isClause() {}
+
+// MarshalJSON marshals the clause as a terms query
+func (c *TermsClause) MarshalJSON() ([]byte, error) {
+	return json.Marshal(object{"terms": object{c.field: c.values}})
+}
+
+// ExistsClause matches documents with a value in a field
+type ExistsClause struct {
+	field string
+}
+
+// Exists returns a clause matching documents with a value in field
+func Exists(field string) *ExistsClause {
+	return &ExistsClause{field: field}
+}
+
+func (*ExistsClause) isClause() {}
+
+// MarshalJSON marshals the clause as an exists query
+func (c *ExistsClause) MarshalJSON() ([]byte, error) {
+	return json.Marshal(object{"exists": object{"field": c.field}})
+}
+
+// RangeClause matches values of a field within bounds
+type RangeClause struct {
+	field  string
+	bounds object
+}
+
+// Range returns a clause matching values of field within the bounds which are set
+func Range(field string) *RangeClause {
+	return &RangeClause{field: field, bounds: object{}}
+}
+
+// Gt sets the exclusive lower bound
+func (c *RangeClause) Gt(value interface{}) *RangeClause {
+	c.bounds["gt"] = value
+	return c
+}
+
+// Gte sets the inclusive lower bound
+func (c *RangeClause) Gte(value interface{}) *RangeClause {
+	c.bounds["gte"] = value
+	return c
+}
+
+// Lt sets the exclusive upper bound
+func (c *RangeClause) Lt(value interface{}) *RangeClause {
+	c.bounds["lt"] = value
+	return c
+}
+
+// Lte sets the inclusive upper bound
+func (c *RangeClause) Lte(value interface{}) *RangeClause {
+	c.bounds["lte"] = value
+	return c
+}
+
+func (*RangeClause) isClause() {}
+
+// MarshalJSON marshals the clause as a range query
+func (c *RangeClause) MarshalJSON() ([]byte, error) {
+	return json.Marshal(object{"range": object{c.field: c.bounds}})
+}
+
+// MatchAllClause matches every document
+type MatchAllClause struct{}
+
+// MatchAll returns a clause matching every document
+func MatchAll() *MatchAllClause {
+	return &MatchAllClause{}
+}
+
+func (*MatchAllClause) isClause() {}
+
+// MarshalJSON marshals the clause as a match_all query
+func (c *MatchAllClause) MarshalJSON() ([]byte, error) {
+	return json.Marshal(object{"match_all": object{}})
+}
+
+// BoolClause combines other clauses. Documents must match every must and filter clause, and no must_not
+// clause, and are scored by the must and should clauses they match.
+type BoolClause struct {
+	must               []Clause
+	should             []Clause
+	filter             []Clause
+	mustNot            []Clause
+	minimumShouldMatch string
+	boost              float64
+}
+
+// Bool returns an empty bool clause
+func Bool() *BoolClause {
+	return &BoolClause{}
+}
+
+// Must adds clauses which documents must match, contributing to their score
+func (c *BoolClause) Must(clauses ...Clause) *BoolClause {
+	c.must = append(c.must, clauses...)
+	return c
+}
+
+// Should adds clauses which documents should match, contributing to their score
+func (c *BoolClause) Should(clauses ...Clause) *BoolClause {
+	c.should = append(c.should, clauses...)
+	return c
+}
+
+// Filter adds clauses which documents must match, without contributing to their score
+func (c *BoolClause) Filter(clauses ...Clause) *BoolClause {
+	c.filter = append(c.filter, clauses...)
+	return c
+}
+
+// MustNot adds clauses which documents must not match
+func (c *BoolClause) MustNot(clauses ...Clause) *BoolClause {
+	c.mustNot = append(c.mustNot, clauses...)
+	return c
+}
+
+// MinimumShouldMatch is the number, or percentage, of should clauses documents must match, e.g. 1 or 75%
+func (c *BoolClause) MinimumShouldMatch(minimum string) *BoolClause {
+	c.minimumShouldMatch = minimum
+	return c
+}
+
+// Boost multiplies the score of documents which match
+func (c *BoolClause) Boost(boost float64) *BoolClause {
+	c.boost = boost
+	return c
+}
+
+func (*BoolClause) isClause() {}
+
+// MarshalJSON marshals the clause as a bool query, leaving out empty lists of clauses
+func (c *BoolClause) MarshalJSON() ([]byte, error) {
+	options := object{}
+	setIf(options, "must", c.must, len(c.must) > 0)
+	setIf(options, "should", c.should, len(c.should) > 0)
+	setIf(options, "filter", c.filter, len(c.filter) > 0)
+	setIf(options, "must_not", c.mustNot, len(c.mustNot) > 0)
+	setIf(options, "minimum_should_match", c.minimumShouldMatch, c.minimumShouldMatch != "")
+	setIf(options, "boost", c.boost, c.boost != 0)
+
+	return json.Marshal(object{"bool": options})
+}
+
+// object is a JSON object, which is marshalled with its keys in order
+type object map[string]interface{}
+
+// setIf sets key to value when the condition holds, so that unset options are left out
+func setIf(o object, key string, value interface{}, condition bool) {
+	if condition {
+		o[key] = value
+	}
+}
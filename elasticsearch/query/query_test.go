@@ -0,0 +1,94 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// update rewrites the golden files from the clauses, run with: go test ./elasticsearch/query -update
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// goldenCases is every clause, function and request the builder can emit, keyed by the name of its golden file
+var goldenCases = map[string]interface{}{
+	"match":                 Match("label", "england"),
+	"match_options":         Match("label", "england").Boost(2).Operator("and").Fuzziness("AUTO"),
+	"multi_match":           MultiMatch("england", "label^2", "code"),
+	"multi_match_options":   MultiMatch("england", "label", "code").Type("best_fields").Boost(1.5).Operator("or").Fuzziness("AUTO"),
+	"term":                  Term("code.keyword", "E92000001"),
+	"term_boost":            Term("has_data", true).Boost(3),
+	"terms":                 Terms("code.keyword", "E92000001", "W92000004"),
+	"exists":                Exists("url"),
+	"range":                 Range("number_of_children").Gt(0).Lte(100),
+	"range_inclusive":       Range("number_of_children").Gte(1).Lt(10),
+	"match_all":             MatchAll(),
+	"bool_empty":            Bool(),
+	"bool":                  Bool().Must(Match("label", "england")).Should(Term("has_data", true)).Filter(Exists("url")).MustNot(Term("code.keyword", "K02000001")).MinimumShouldMatch("1").Boost(2),
+	"bool_nested":           Bool().Should(Bool().Must(Match("label", "north"), Match("label", "east")), Match("code", "E12000001")),
+	"function_score":        FunctionScore(MatchAll()),
+	"function_score_weight": FunctionScore(Match("label", "england")).Add(Weight(2, Term("has_data", true)), Weight(0.5, nil)).ScoreMode("multiply").BoostMode("sum").MaxBoost(10),
+	"function_score_field_value_factor": FunctionScore(Match("label", "england")).Add(
+		FieldValueFactor("number_of_children").Factor(1.2).Modifier("log1p").Missing(0).Filter(Exists("number_of_children")),
+	),
+	"function_score_decay": FunctionScore(Match("label", "england")).Add(
+		Decay(DecayExp, "depth", 0, 1).Offset(1).Decay(0.25).Weight(2).Filter(Term("has_data", true)),
+	),
+	"field_value_factor": FieldValueFactor("number_of_children"),
+	"decay":              Decay(DecayGauss, "depth", 0, 2),
+	"sort":               []Sort{ScoreSort(Descending), SortField("label.keyword", Ascending)},
+	"sort_unmapped":      []Sort{SortField("code.keyword", Ascending).Unmapped("keyword")},
+	"request": &Request{
+		From:  20,
+		Size:  10,
+		Query: Bool().Should(Match("label", "england"), Match("code", "england")),
+		Sort:  []Sort{ScoreSort(Descending)},
+	},
+	"request_track_total_hits": &Request{
+		Size:           10,
+		Query:          MatchAll(),
+		TrackTotalHits: true,
+	},
+	"request_highlight": &Request{
+		Size: 10,
+		Highlight: &Highlight{
+			PreTags:  []string{"<em>"},
+			PostTags: []string{"</em>"},
+			Fields:   map[string]HighlightField{"label": {}, "code": {}},
+		},
+		Query: MatchAll(),
+	},
+}
+
+func TestGolden(t *testing.T) {
+	names := make([]string, 0, len(goldenCases))
+	for name := range goldenCases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	Convey("Given every clause the query builder can emit", t, func() {
+		for _, name := range names {
+			actual, err := json.MarshalIndent(goldenCases[name], "", "  ")
+			So(err, ShouldBeNil)
+			actual = append(actual, '\n')
+
+			path := filepath.Join("testdata", name+".json")
+			if *update {
+				So(os.WriteFile(path, actual, 0o644), ShouldBeNil)
+			}
+
+			Convey("Then "+name+" is marshalled as in its golden file", func() {
+				expected, err := os.ReadFile(path)
+				So(err, ShouldBeNil)
+				So(string(actual), ShouldEqual, string(expected))
+				So(json.Valid(bytes.TrimSpace(actual)), ShouldBeTrue)
+			})
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package query
+
+import "encoding/json"
+
+// Sort orders
+const (
+	Ascending  = "asc"
+	Descending = "desc"
+)
+
+// Request is the body of a search request
+type Request struct {
+	From      int        `json:"from"`
+	Size      int        `json:"size"`
+	Highlight *Highlight `json:"highlight,omitempty"`
+	Query     Clause     `json:"query"`
+	Sort      []Sort     `json:"sort,omitempty"`
+
+	// TrackTotalHits is true to count every matching document, or the number of documents to count up to
+	// before reporting the total as a lower bound. Elasticsearch counts up to 10,000 when it is not set.
+	TrackTotalHits interface{} `json:"track_total_hits,omitempty"`
+}
+
+// Highlight requests the parts of fields which matched, wrapped in tags
+type Highlight struct {
+	PreTags  []string                  `json:"pre_tags,omitempty"`
+	PostTags []string                  `json:"post_tags,omitempty"`
+	Fields   map[string]HighlightField `json:"fields,omitempty"`
+}
+
+// HighlightField is the options for highlighting a single field, the defaults are used for every field
+type HighlightField struct{}
+
+// Sort orders search results by a field, or by their score
+type Sort struct {
+	Field        string
+	Order        string
+	UnmappedType string
+}
+
+// SortField returns a sort by field in order
+func SortField(field, order string) Sort {
+	return Sort{Field: field, Order: order}
+}
+
+// ScoreSort returns a sort by relevance in order
+func ScoreSort(order string) Sort {
+	return Sort{Field: "_score", Order: order}
+}
+
+// Unmapped returns the sort, treating the field as fieldType in indexes where it is not mapped, rather than failing
+func (s Sort) Unmapped(fieldType string) Sort {
+	s.UnmappedType = fieldType
+	return s
+}
+
+// MarshalJSON marshals the sort with its field as the key
+func (s Sort) MarshalJSON() ([]byte, error) {
+	options := object{"order": s.Order}
+	setIf(options, "unmapped_type", s.UnmappedType, s.UnmappedType != "")
+	return json.Marshal(object{s.Field: options})
+}
@@ -0,0 +1,404 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+const (
+	// TemplateName is the name the dimension search index template is installed under
+	TemplateName = "dimension-search"
+
+	// TemplateVersion should be incremented whenever the expected template changes, so that
+	// the installed template is replaced
+	TemplateVersion = 2
+
+	// TemplatePattern matches the names of dimension search indexes, {instanceID}_{dimension}.{version}
+	TemplatePattern = "*_*" + VersionSeparator + "*"
+)
+
+// indexTemplate represents a legacy index template, applied to indexes when they are created
+type indexTemplate struct {
+	IndexPatterns []string               `json:"index_patterns"`
+	Version       int                    `json:"version"`
+	Order         int                    `json:"order"`
+	Settings      map[string]interface{} `json:"settings,omitempty"`
+	Mappings      json.RawMessage        `json:"mappings"`
+}
+
+// composableIndexTemplate represents a composable index template, the only kind supported by opensearch serverless
+type composableIndexTemplate struct {
+	IndexPatterns []string               `json:"index_patterns"`
+	Version       int                    `json:"version"`
+	Template      composableTemplateBody `json:"template"`
+}
+
+// composableTemplateBody represents the settings and mappings applied to indexes created from a composable index template
+type composableTemplateBody struct {
+	Settings map[string]interface{} `json:"settings,omitempty"`
+	Mappings json.RawMessage        `json:"mappings"`
+}
+
+// composableIndexTemplates represents the response to a request for composable index templates
+type composableIndexTemplates struct {
+	IndexTemplates []struct {
+		Name          string                  `json:"name"`
+		IndexTemplate composableIndexTemplate `json:"index_template"`
+	} `json:"index_templates"`
+}
+
+// mapping represents the mapping of the fields in an index
+type mapping struct {
+	Properties map[string]field `json:"properties"`
+}
+
+// field represents the mapping of a single field, along with any sub-fields indexed differently
+type field struct {
+	Type       string           `json:"type,omitempty"`
+	Analyzer   string           `json:"analyzer,omitempty"`
+	Normalizer string           `json:"normalizer,omitempty"`
+	Fields     map[string]field `json:"fields,omitempty"`
+	Properties map[string]field `json:"properties,omitempty"`
+}
+
+// expectedMapping returns the mapping dimension search indexes should be created with. The keyword
+// sub-fields allow exact matching and sorting on the code and label of a dimension option, and depth, the
+// level of an option in its hierarchy, is used to rank options.
+func expectedMapping() mapping {
+	return mapping{Properties: map[string]field{
+		"code": {
+			Type:   "text",
+			Fields: map[string]field{"keyword": {Type: "keyword"}},
+		},
+		"label": {
+			Type:   "text",
+			Fields: map[string]field{"keyword": {Type: "keyword", Normalizer: "sortable"}},
+		},
+		"url":                {Type: "keyword"},
+		"has_data":           {Type: "boolean"},
+		"number_of_children": {Type: "integer"},
+		"depth":              {Type: "integer"},
+	}}
+}
+
+// expectedTemplate returns the index template dimension search indexes should be created from
+func expectedTemplate() *indexTemplate {
+	mappings, _ := json.Marshal(expectedMapping())
+
+	return &indexTemplate{
+		IndexPatterns: []string{TemplatePattern},
+		Version:       TemplateVersion,
+		Settings: map[string]interface{}{
+			"analysis": map[string]interface{}{
+				"normalizer": map[string]interface{}{
+					"sortable": map[string]interface{}{
+						"type":   "custom",
+						"filter": []string{"lowercase", "asciifolding"},
+					},
+				},
+			},
+		},
+		Mappings: mappings,
+	}
+}
+
+// expectedComposableTemplate returns the expected index template as a composable template
+func expectedComposableTemplate() *composableIndexTemplate {
+	legacy := expectedTemplate()
+
+	return &composableIndexTemplate{
+		IndexPatterns: legacy.IndexPatterns,
+		Version:       legacy.Version,
+		Template: composableTemplateBody{
+			Settings: legacy.Settings,
+			Mappings: legacy.Mappings,
+		},
+	}
+}
+
+// parseComposableTemplate reads the dimension search composable index template in the same form as a legacy template
+func parseComposableTemplate(responseBody []byte) (*indexTemplate, error) {
+	templates := composableIndexTemplates{}
+	if err := json.Unmarshal(responseBody, &templates); err != nil {
+		return nil, errs.ErrUnmarshallingJSON
+	}
+
+	for _, template := range templates.IndexTemplates {
+		if template.Name == TemplateName {
+			return &indexTemplate{
+				IndexPatterns: template.IndexTemplate.IndexPatterns,
+				Version:       template.IndexTemplate.Version,
+				Settings:      template.IndexTemplate.Template.Settings,
+				Mappings:      template.IndexTemplate.Template.Mappings,
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CheckTemplate reports how the installed index template, and the mappings of existing dimension
+// search indexes, differ from what is expected. Fields which are not expected are not reported.
+func (api *API) CheckTemplate(ctx context.Context) (*models.IndexTemplateReport, error) {
+	report := &models.IndexTemplateReport{
+		Name:    TemplateName,
+		Version: TemplateVersion,
+		Drift:   []models.MappingDrift{},
+	}
+
+	installed, err := api.getTemplate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := expectedMapping()
+
+	if installed == nil {
+		report.Drift = append(report.Drift, compareMappings("", expected, mapping{})...)
+	} else {
+		report.InstalledVersion = installed.Version
+
+		actual, err := parseMapping(installed.Mappings)
+		if err != nil {
+			return nil, err
+		}
+		report.Drift = append(report.Drift, compareMappings("", expected, actual)...)
+	}
+
+	indexes, err := api.getIndexMappings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		report.Drift = append(report.Drift, compareMappings(name, expected, indexes[name])...)
+	}
+
+	return report, nil
+}
+
+// InstallTemplate installs the expected index template, unless the same or a newer version, without
+// drift, is already installed. Existing indexes keep their mapping until they are rebuilt.
+func (api *API) InstallTemplate(ctx context.Context) (*models.IndexTemplateReport, error) {
+	logData := log.Data{"template": TemplateName, "version": TemplateVersion}
+
+	report, err := api.CheckTemplate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logData["installed_version"] = report.InstalledVersion
+
+	if report.InstalledVersion > TemplateVersion {
+		log.Info(ctx, "a newer index template is installed, leaving it in place", logData)
+		return report, nil
+	}
+
+	if report.InstalledVersion == TemplateVersion && !report.TemplateDrifted() {
+		return report, nil
+	}
+
+	if err = api.checkTemplateSupported(ctx); err != nil {
+		log.Error(ctx, "index template cannot be installed on this cluster", err, logData)
+		return nil, err
+	}
+
+	var template interface{} = expectedTemplate()
+	if api.serverless() {
+		template = expectedComposableTemplate()
+	}
+
+	payload, err := json.Marshal(template)
+	if err != nil {
+		return nil, errs.ErrMarshallingQuery
+	}
+
+	if _, _, err = api.CallElastic(ctx, api.templatePath(), "PUT", payload); err != nil {
+		log.Error(ctx, "failed to install index template", err, logData)
+		return nil, err
+	}
+
+	log.Info(ctx, "index template installed", logData)
+
+	if report, err = api.CheckTemplate(ctx); err != nil {
+		return nil, err
+	}
+	report.Updated = true
+
+	return report, nil
+}
+
+// checkTemplateSupported returns ErrTemplateNotSupported if the cluster is elasticsearch 6, which requires the
+// mapping of a template to be nested beneath a document type
+func (api *API) checkTemplateSupported(ctx context.Context) error {
+	if api.serverless() {
+		return nil
+	}
+
+	version := api.Version()
+	if version == nil {
+		var err error
+		if version, err = api.DetectVersion(ctx); err != nil {
+			return err
+		}
+	}
+
+	if major, _ := version.Major(); version.Distribution == "" && major == 6 {
+		return errs.ErrTemplateNotSupported
+	}
+	return nil
+}
+
+// templatePath returns the path of the index template, which is a composable template for opensearch serverless
+func (api *API) templatePath() string {
+	if api.serverless() {
+		return api.url + "/_index_template/" + TemplateName
+	}
+	return api.url + "/_template/" + TemplateName
+}
+
+// getTemplate returns the installed index template, or nil if it has not been installed
+func (api *API) getTemplate(ctx context.Context) (*indexTemplate, error) {
+	responseBody, status, err := api.CallElastic(ctx, api.templatePath(), "GET", nil)
+	if err != nil {
+		if status == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if api.serverless() {
+		return parseComposableTemplate(responseBody)
+	}
+
+	templates := make(map[string]*indexTemplate)
+	if err = json.Unmarshal(responseBody, &templates); err != nil {
+		return nil, errs.ErrUnmarshallingJSON
+	}
+
+	return templates[TemplateName], nil
+}
+
+// getIndexMappings returns the mapping of every dimension search index, keyed by index name
+func (api *API) getIndexMappings(ctx context.Context) (map[string]mapping, error) {
+	path := api.url + "/" + TemplatePattern + "/_mapping?allow_no_indices=true&ignore_unavailable=true"
+
+	responseBody, _, err := api.CallElastic(ctx, path, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make(map[string]struct {
+		Mappings json.RawMessage `json:"mappings"`
+	})
+	if err = json.Unmarshal(responseBody, &indexes); err != nil {
+		return nil, errs.ErrUnmarshallingJSON
+	}
+
+	mappings := make(map[string]mapping, len(indexes))
+	for name, index := range indexes {
+		// skip system and hidden indexes
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		if mappings[name], err = parseMapping(index.Mappings); err != nil {
+			return nil, err
+		}
+	}
+
+	return mappings, nil
+}
+
+// parseMapping reads a mapping, which elasticsearch 6 nests beneath a single document type
+func parseMapping(raw json.RawMessage) (mapping, error) {
+	m := mapping{}
+	if len(raw) == 0 {
+		return m, nil
+	}
+
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return m, errs.ErrUnmarshallingJSON
+	}
+	if m.Properties != nil {
+		return m, nil
+	}
+
+	typed := make(map[string]mapping)
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return m, errs.ErrUnmarshallingJSON
+	}
+	for _, docType := range typed {
+		return docType, nil
+	}
+
+	return m, nil
+}
+
+// compareMappings returns a drift for every expected field, or sub-field, which is missing or mapped differently
+func compareMappings(index string, expected, actual mapping) []models.MappingDrift {
+	expectedFields := flattenFields("", expected.Properties)
+	actualFields := flattenFields("", actual.Properties)
+
+	names := make([]string, 0, len(expectedFields))
+	for name := range expectedFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	drift := []models.MappingDrift{}
+	for _, name := range names {
+		if expectedFields[name] != actualFields[name] {
+			drift = append(drift, models.MappingDrift{
+				Index:    index,
+				Field:    name,
+				Expected: expectedFields[name],
+				Actual:   actualFields[name],
+			})
+		}
+	}
+
+	return drift
+}
+
+// flattenFields describes every field and sub-field by its dotted path
+func flattenFields(prefix string, fields map[string]field) map[string]string {
+	flattened := make(map[string]string)
+
+	for name, f := range fields {
+		path := prefix + name
+
+		description := f.Type
+		if description == "" && f.Properties != nil {
+			description = "object"
+		}
+		if f.Analyzer != "" {
+			description += " analyzer=" + f.Analyzer
+		}
+		if f.Normalizer != "" {
+			description += " normalizer=" + f.Normalizer
+		}
+		flattened[path] = description
+
+		for subPath, subDescription := range flattenFields(path+".", f.Fields) {
+			flattened[subPath] = subDescription
+		}
+		for subPath, subDescription := range flattenFields(path+".", f.Properties) {
+			flattened[subPath] = subDescription
+		}
+	}
+
+	return flattened
+}
@@ -0,0 +1,114 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// legacyMapping is the mapping dynamically created for an index when no template has been installed
+const legacyMapping = `{"properties":{"code":{"type":"text"},"label":{"type":"text","fields":{"keyword":{"type":"keyword","ignore_above":256}}},"url":{"type":"text"},"has_data":{"type":"boolean"},"number_of_children":{"type":"long"}}}`
+
+func TestCheckTemplate(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given no template is installed and an index was created with a dynamic mapping", t, func() {
+		cluster := newFakeCluster()
+		cluster.addIndex("123_aggregate.1", 1000)
+		cluster.mappings["123_aggregate.1"] = json.RawMessage(legacyMapping)
+
+		api, server := cluster.newAPI()
+		defer server.Close()
+
+		Convey("When the template is checked", func() {
+			report, err := api.CheckTemplate(ctx)
+
+			Convey("Then every expected field of the template, and the differing fields of the index, are reported", func() {
+				So(err, ShouldBeNil)
+				So(report.Name, ShouldEqual, TemplateName)
+				So(report.InstalledVersion, ShouldEqual, 0)
+				So(report.Updated, ShouldBeFalse)
+				So(report.TemplateDrifted(), ShouldBeTrue)
+				So(report.Drift, ShouldContain, models.MappingDrift{Field: "label.keyword", Expected: "keyword normalizer=sortable"})
+				So(report.Drift, ShouldContain, models.MappingDrift{Index: "123_aggregate.1", Field: "code.keyword", Expected: "keyword"})
+				So(report.Drift, ShouldContain, models.MappingDrift{Index: "123_aggregate.1", Field: "label.keyword", Expected: "keyword normalizer=sortable", Actual: "keyword"})
+				So(report.Drift, ShouldContain, models.MappingDrift{Index: "123_aggregate.1", Field: "number_of_children", Expected: "integer", Actual: "long"})
+				So(report.Drift, ShouldContain, models.MappingDrift{Index: "123_aggregate.1", Field: "url", Expected: "keyword", Actual: "text"})
+				So(report.Drift, ShouldNotContain, models.MappingDrift{Index: "123_aggregate.1", Field: "has_data", Expected: "boolean", Actual: "boolean"})
+			})
+		})
+
+		Convey("When the template is installed", func() {
+			report, err := api.InstallTemplate(ctx)
+
+			Convey("Then the template no longer drifts but the existing index still does until it is rebuilt", func() {
+				So(err, ShouldBeNil)
+				So(report.Updated, ShouldBeTrue)
+				So(report.InstalledVersion, ShouldEqual, TemplateVersion)
+				So(report.TemplateDrifted(), ShouldBeFalse)
+				So(report.Drift, ShouldNotBeEmpty)
+				So(cluster.templates, ShouldContainKey, TemplateName)
+			})
+
+			Convey("Then installing it again leaves it in place", func() {
+				report, err = api.InstallTemplate(ctx)
+				So(err, ShouldBeNil)
+				So(report.Updated, ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given a newer version of the template is installed", t, func() {
+		cluster := newFakeCluster()
+		cluster.templates[TemplateName] = json.RawMessage(`{"index_patterns":["*_*"],"version":99,"mappings":{}}`)
+
+		api, server := cluster.newAPI()
+		defer server.Close()
+
+		Convey("When the template is installed the newer version is left in place", func() {
+			report, err := api.InstallTemplate(ctx)
+			So(err, ShouldBeNil)
+			So(report.Updated, ShouldBeFalse)
+			So(report.InstalledVersion, ShouldEqual, 99)
+			So(string(cluster.templates[TemplateName]), ShouldContainSubstring, `"version":99`)
+		})
+	})
+
+	Convey("Given an elasticsearch 6 cluster, which requires a document type in the mapping of a template", t, func() {
+		cluster := newFakeCluster()
+		cluster.info = `{"name":"node-1","version":{"number":"6.8.23"},"tagline":"You Know, for Search"}`
+
+		api, server := cluster.newAPI()
+		defer server.Close()
+
+		Convey("When the template is installed it is refused and nothing is installed", func() {
+			report, err := api.InstallTemplate(ctx)
+			So(err, ShouldEqual, errs.ErrTemplateNotSupported)
+			So(report, ShouldBeNil)
+			So(cluster.templates, ShouldBeEmpty)
+		})
+
+		Convey("When the template is checked the missing template is reported", func() {
+			report, err := api.CheckTemplate(ctx)
+			So(err, ShouldBeNil)
+			So(report.TemplateDrifted(), ShouldBeTrue)
+		})
+	})
+}
+
+func TestParseMapping(t *testing.T) {
+	Convey("Given a mapping nested beneath a document type, as returned by elasticsearch 6", t, func() {
+		raw := json.RawMessage(`{"_doc":` + legacyMapping + `}`)
+
+		Convey("When it is parsed the fields of the document type are returned", func() {
+			m, err := parseMapping(raw)
+			So(err, ShouldBeNil)
+			So(m.Properties["number_of_children"].Type, ShouldEqual, "long")
+			So(m.Properties["label"].Fields["keyword"].Type, ShouldEqual, "keyword")
+		})
+	})
+}
@@ -0,0 +1,266 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// Names of the codecs used to search each supported version of elasticsearch and opensearch
+const (
+	CodecES6         = "es6"
+	CodecES7         = "es7"
+	CodecES8         = "es8"
+	CodecOpenSearch1 = "opensearch1"
+	CodecOpenSearch2 = "opensearch2"
+)
+
+// Distributions of a cluster. Opensearch clusters report their distribution, elasticsearch clusters report none
+// and opensearch serverless collections do not report a version at all.
+const (
+	DistributionOpenSearch           = "opensearch"
+	DistributionOpenSearchServerless = "opensearch-serverless"
+)
+
+// versionProbeInterval is the least time between attempts to detect the cluster version while searching
+const versionProbeInterval = 30 * time.Second
+
+// ErrUnsupportedVersion is returned when the cluster is a version of elasticsearch or opensearch which cannot be searched
+var ErrUnsupportedVersion = errors.New("unsupported elasticsearch version")
+
+// defaultCodec is used to search elasticsearch until the cluster version has been detected
+var defaultCodec Codec = &codec{name: CodecES7}
+
+// defaultOpenSearchCodec is used to search opensearch until the cluster version has been detected
+var defaultOpenSearchCodec Codec = &codec{name: CodecOpenSearch2}
+
+// ClusterVersion is the version of elasticsearch, or opensearch, reported by the cluster
+type ClusterVersion struct {
+	Distribution string `json:"distribution"`
+	Number       string `json:"number"`
+}
+
+// clusterInfo is the response from the root of the cluster
+type clusterInfo struct {
+	Version ClusterVersion `json:"version"`
+}
+
+func (v ClusterVersion) String() string {
+	if v.Distribution == DistributionOpenSearchServerless {
+		return "opensearch serverless"
+	}
+	if v.Distribution == DistributionOpenSearch {
+		return "opensearch " + v.Number
+	}
+	return "elasticsearch " + v.Number
+}
+
+// Major returns the major version number
+func (v ClusterVersion) Major() (int, error) {
+	major, _, _ := strings.Cut(v.Number, ".")
+	return strconv.Atoi(major)
+}
+
+// Codec builds search queries for, and decodes search responses from, a version of elasticsearch or opensearch
+type Codec interface {
+	Name() string
+	EncodeSearchQuery(term string, limit, offset int, boost *models.RankingBoost, exactTotal bool) ([]byte, error)
+	DecodeSearchResponse(body []byte) (*models.SearchResponse, error)
+}
+
+// CodecFor returns the codec for a version of elasticsearch or opensearch. Versions newer than those
+// supported use the codec for the newest supported version.
+func CodecFor(version ClusterVersion) (Codec, error) {
+	if version.Distribution == DistributionOpenSearchServerless {
+		return &codec{name: CodecOpenSearch2}, nil
+	}
+
+	major, err := version.Major()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+	}
+
+	if version.Distribution == DistributionOpenSearch {
+		switch {
+		case major == 1:
+			return &codec{name: CodecOpenSearch1}, nil
+		case major >= 2:
+			return &codec{name: CodecOpenSearch2}, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+	}
+
+	switch {
+	case major == 6:
+		return &codec{name: CodecES6, totalAsNumber: true}, nil
+	case major == 7:
+		return &codec{name: CodecES7}, nil
+	case major >= 8:
+		return &codec{name: CodecES8}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+}
+
+type codec struct {
+	name string
+
+	// elasticsearch 6 reports the total number of hits as a number, rather than an object with a relation
+	totalAsNumber bool
+}
+
+func (c *codec) Name() string {
+	return c.name
+}
+
+// EncodeSearchQuery asks for every matching document to be counted when exactTotal is true. Elasticsearch 6
+// always counts every matching document.
+func (c *codec) EncodeSearchQuery(term string, limit, offset int, boost *models.RankingBoost, exactTotal bool) ([]byte, error) {
+	request := buildSearchQuery(term, limit, offset, boost)
+	if exactTotal && !c.totalAsNumber {
+		request.TrackTotalHits = true
+	}
+	return json.Marshal(request)
+}
+
+func (c *codec) DecodeSearchResponse(body []byte) (*models.SearchResponse, error) {
+	if !c.totalAsNumber {
+		response := &models.SearchResponse{}
+		if err := json.Unmarshal(body, response); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+
+	responseES6 := &models.SearchResponseES6{}
+	if err := json.Unmarshal(body, responseES6); err != nil {
+		return nil, err
+	}
+	return &models.SearchResponse{Hits: models.Hits{
+		Total: models.Total{
+			Value:    responseES6.Hits.Total,
+			Relation: models.TotalCountExact,
+		},
+		HitList: responseES6.Hits.HitList,
+	}}, nil
+}
+
+// DetectVersion asks the primary cluster for its version and chooses the codec used to search it. Opensearch
+// serverless collections, which do not report a version, are searched as the latest opensearch.
+func (api *API) DetectVersion(ctx context.Context) (*ClusterVersion, error) {
+	return api.detectVersion(ctx, api.endpoints[0])
+}
+
+// detectVersion asks the cluster at endpoint for its version and chooses the codec used to search it
+func (api *API) detectVersion(ctx context.Context, endpoint *Endpoint) (*ClusterVersion, error) {
+	logData := log.Data{"url": endpoint.URL}
+
+	version := ClusterVersion{Distribution: DistributionOpenSearchServerless}
+	if !api.serverless() {
+		responseBody, _, err := api.callEndpoint(ctx, endpoint, endpoint.URL+"/", http.MethodGet, nil)
+		if err != nil {
+			log.Error(ctx, "failed to detect elasticsearch version", err, logData)
+			return nil, err
+		}
+
+		info := &clusterInfo{}
+		if err = json.Unmarshal(responseBody, info); err != nil {
+			log.Error(ctx, "failed to parse elasticsearch version", err, logData)
+			return nil, err
+		}
+		version = info.Version
+	}
+	logData["version"] = version.String()
+
+	codec, err := CodecFor(version)
+	if err != nil {
+		log.Error(ctx, "elasticsearch version is not supported", err, logData)
+		return nil, err
+	}
+	logData["codec"] = codec.Name()
+
+	endpoint.versionMu.Lock()
+	endpoint.version = &version
+	endpoint.codec = codec
+	endpoint.versionStale = false
+	endpoint.versionMu.Unlock()
+
+	log.Info(ctx, "detected elasticsearch version", logData)
+	return &version, nil
+}
+
+// Version returns the detected version of the primary cluster, or nil if it has not been detected
+func (api *API) Version() *ClusterVersion {
+	primary := api.endpoints[0]
+	primary.versionMu.Lock()
+	defer primary.versionMu.Unlock()
+	return primary.version
+}
+
+// searchCodec returns the codec for the cluster at endpoint, detecting its version if it has not been detected,
+// or the cluster may have been replaced since it was. The last codec, or the default, is used until detection
+// succeeds.
+func (api *API) searchCodec(ctx context.Context, endpoint *Endpoint) Codec {
+	endpoint.versionMu.Lock()
+	current := endpoint.codec
+	if current != nil && !endpoint.versionStale {
+		endpoint.versionMu.Unlock()
+		return current
+	}
+	if current == nil {
+		current = defaultCodec
+		if api.backend == BackendOpenSearch {
+			current = defaultOpenSearchCodec
+		}
+	}
+	if time.Since(endpoint.lastVersionProbe) < versionProbeInterval {
+		endpoint.versionMu.Unlock()
+		return current
+	}
+	endpoint.lastVersionProbe = time.Now()
+	endpoint.versionMu.Unlock()
+
+	if _, err := api.detectVersion(ctx, endpoint); err != nil {
+		log.Warn(ctx, "searching with the last known codec as the elasticsearch version could not be detected", log.Data{"url": endpoint.URL, "codec": current.Name()})
+		return current
+	}
+
+	endpoint.versionMu.Lock()
+	defer endpoint.versionMu.Unlock()
+	return endpoint.codec
+}
+
+// markVersionStale records that the cluster could not be reached, so should have its version detected again
+func (e *Endpoint) markVersionStale() {
+	e.versionMu.Lock()
+	e.versionStale = true
+	e.versionMu.Unlock()
+}
+
+// VersionChecker reports the detected version of the primary cluster and the codec used to search it, detecting
+// the version if it has not been detected
+func (api *API) VersionChecker(ctx context.Context, state *healthcheck.CheckState) error {
+	primary := api.endpoints[0]
+
+	primary.versionMu.Lock()
+	detected := primary.codec != nil && !primary.versionStale
+	primary.versionMu.Unlock()
+
+	if !detected {
+		if _, err := api.DetectVersion(ctx); err != nil {
+			return state.Update(healthcheck.StatusWarning, "elasticsearch version could not be detected: "+err.Error(), 0)
+		}
+	}
+
+	primary.versionMu.Lock()
+	defer primary.versionMu.Unlock()
+	return state.Update(healthcheck.StatusOK, fmt.Sprintf("%s, searched with the %s codec", primary.version, primary.codec.Name()), http.StatusOK)
+}
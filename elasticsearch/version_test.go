@@ -0,0 +1,193 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	es6SearchResponse = `{"hits":{"total":2,"hits":[{"_score":1.5,"_source":{"code":"K02000001","label":"United Kingdom"}},{"_score":1,"_source":{"code":"E92000001","label":"England"}}]}}`
+	es7SearchResponse = `{"hits":{"total":{"value":2,"relation":"eq"},"hits":[{"_score":1.5,"_source":{"code":"K02000001","label":"United Kingdom"}},{"_score":1,"_source":{"code":"E92000001","label":"England"}}]}}`
+)
+
+// versionedCluster responds to requests for its version and to searches, counting the version requests
+type versionedCluster struct {
+	info           string
+	searchResponse string
+	versionProbes  int
+}
+
+func (c *versionedCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		c.versionProbes++
+		w.Write([]byte(c.info))
+	case "/123_aggregate/_search":
+		w.Write([]byte(c.searchResponse))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestCodecFor(t *testing.T) {
+	Convey("Given the versions reported by elasticsearch and opensearch clusters", t, func() {
+		cases := map[ClusterVersion]string{
+			{Number: "6.8.23"}: CodecES6,
+			{Number: "7.10.2"}: CodecES7,
+			{Number: "8.11.1"}: CodecES8,
+			{Number: "9.0.0"}:  CodecES8,
+			{Distribution: DistributionOpenSearch, Number: "1.3.14"}: CodecOpenSearch1,
+			{Distribution: DistributionOpenSearch, Number: "2.11.0"}: CodecOpenSearch2,
+		}
+
+		Convey("Then each is searched with the codec for its version", func() {
+			for version, name := range cases {
+				codec, err := CodecFor(version)
+				So(err, ShouldBeNil)
+				So(codec.Name(), ShouldEqual, name)
+			}
+		})
+	})
+
+	Convey("Given versions which cannot be searched", t, func() {
+		versions := []ClusterVersion{{Number: "5.6.16"}, {Number: ""}, {Distribution: DistributionOpenSearch, Number: "0.1.0"}}
+
+		Convey("Then no codec is returned", func() {
+			for _, version := range versions {
+				_, err := CodecFor(version)
+				So(errors.Is(err, ErrUnsupportedVersion), ShouldBeTrue)
+			}
+		})
+	})
+}
+
+func TestEncodeSearchQuery(t *testing.T) {
+	Convey("Given an elasticsearch 7 codec", t, func() {
+		codec, _ := CodecFor(ClusterVersion{Number: "7.10.2"})
+
+		Convey("Then every matching document is counted only when an exact total is asked for", func() {
+			body, err := codec.EncodeSearchQuery("united", 10, 0, nil, false)
+			So(err, ShouldBeNil)
+			So(string(body), ShouldNotContainSubstring, "track_total_hits")
+
+			body, err = codec.EncodeSearchQuery("united", 10, 0, nil, true)
+			So(err, ShouldBeNil)
+			So(string(body), ShouldContainSubstring, `"track_total_hits":true`)
+		})
+	})
+
+	Convey("Given an elasticsearch 6 codec", t, func() {
+		codec, _ := CodecFor(ClusterVersion{Number: "6.8.23"})
+
+		Convey("Then track_total_hits is never sent, as every matching document is always counted", func() {
+			body, err := codec.EncodeSearchQuery("united", 10, 0, nil, true)
+			So(err, ShouldBeNil)
+			So(string(body), ShouldNotContainSubstring, "track_total_hits")
+		})
+	})
+}
+
+func TestDecodeSearchResponse(t *testing.T) {
+	Convey("Given an elasticsearch 6 search response", t, func() {
+		codec, _ := CodecFor(ClusterVersion{Number: "6.8.23"})
+
+		Convey("Then the total number of hits is decoded as exact", func() {
+			response, err := codec.DecodeSearchResponse([]byte(es6SearchResponse))
+			So(err, ShouldBeNil)
+			So(response.Hits.Total.Value, ShouldEqual, 2)
+			So(response.Hits.Total.Relation, ShouldEqual, models.TotalCountExact)
+			So(response.Hits.HitList, ShouldHaveLength, 2)
+			So(response.Hits.HitList[0].Source.Label, ShouldEqual, "United Kingdom")
+		})
+
+		Convey("Then the elasticsearch 7 codec cannot decode it", func() {
+			_, err := defaultCodec.DecodeSearchResponse([]byte(es6SearchResponse))
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given an opensearch 2 search response", t, func() {
+		codec, _ := CodecFor(ClusterVersion{Distribution: DistributionOpenSearch, Number: "2.11.0"})
+
+		Convey("Then the total number of hits and its relation are decoded", func() {
+			response, err := codec.DecodeSearchResponse([]byte(es7SearchResponse))
+			So(err, ShouldBeNil)
+			So(response.Hits.Total.Value, ShouldEqual, 2)
+			So(response.Hits.Total.Relation, ShouldEqual, models.TotalCountExact)
+			So(response.Hits.HitList[1].Source.Code, ShouldEqual, "E92000001")
+		})
+	})
+}
+
+func TestDetectVersion(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given an elasticsearch 6 cluster", t, func() {
+		cluster := &versionedCluster{
+			info:           `{"name":"node-1","version":{"number":"6.8.23","lucene_version":"7.7.3"},"tagline":"You Know, for Search"}`,
+			searchResponse: es6SearchResponse,
+		}
+		server := httptest.NewServer(cluster)
+		defer server.Close()
+		api := newRetryingAPI(server.URL, 0, 0)
+
+		Convey("When the version is detected", func() {
+			version, err := api.DetectVersion(ctx)
+
+			Convey("Then the version is reported", func() {
+				So(err, ShouldBeNil)
+				So(version.String(), ShouldEqual, "elasticsearch 6.8.23")
+				So(api.Version(), ShouldResemble, version)
+			})
+
+			Convey("Then searches are decoded without detecting the version again", func() {
+				response, _, err := api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+				So(err, ShouldBeNil)
+				So(response.Hits.Total.Value, ShouldEqual, 2)
+				So(cluster.versionProbes, ShouldEqual, 1)
+			})
+
+			Convey("Then the health check reports the version and codec", func() {
+				state := healthcheck.NewCheckState("Elasticsearch Version")
+				So(api.VersionChecker(ctx, state), ShouldBeNil)
+				So(state.Status(), ShouldEqual, healthcheck.StatusOK)
+				So(state.Message(), ShouldEqual, "elasticsearch 6.8.23, searched with the es6 codec")
+			})
+		})
+
+		Convey("When a search is made before the version is detected", func() {
+			response, _, err := api.QuerySearchIndex(ctx, "123", "aggregate", "united", 10, 0, false)
+
+			Convey("Then the version is detected first", func() {
+				So(err, ShouldBeNil)
+				So(response.Hits.HitList, ShouldHaveLength, 2)
+				So(cluster.versionProbes, ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given a cluster which does not report a version", t, func() {
+		cluster := &versionedCluster{info: `{}`}
+		server := httptest.NewServer(cluster)
+		defer server.Close()
+		api := newRetryingAPI(server.URL, 0, 0)
+
+		Convey("When the health check is run", func() {
+			state := healthcheck.NewCheckState("Elasticsearch Version")
+			So(api.VersionChecker(ctx, state), ShouldBeNil)
+
+			Convey("Then it warns that the version could not be detected", func() {
+				So(state.Status(), ShouldEqual, healthcheck.StatusWarning)
+				So(state.Message(), ShouldContainSubstring, ErrUnsupportedVersion.Error())
+				So(api.Version(), ShouldBeNil)
+			})
+		})
+	})
+}
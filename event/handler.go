@@ -0,0 +1,90 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	kafka "github.com/ONSdigital/dp-kafka/v4"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// ErrMissingInstanceID is returned when an instance removed event does not say which instance was removed
+var ErrMissingInstanceID = errors.New("instance removed event has no instance id")
+
+// SearchIndexes - An interface used to list and remove search indexes
+type SearchIndexes interface {
+	ListSearchIndexes(ctx context.Context) ([]string, error)
+	DeleteSearchIndex(ctx context.Context, instanceID, dimension string) (int, error)
+}
+
+// InstanceRemovedHandler deletes the search indexes of every dimension of a removed instance
+type InstanceRemovedHandler struct {
+	searchIndexes SearchIndexes
+}
+
+// NewInstanceRemovedHandler creates an InstanceRemovedHandler
+func NewInstanceRemovedHandler(searchIndexes SearchIndexes) *InstanceRemovedHandler {
+	return &InstanceRemovedHandler{searchIndexes: searchIndexes}
+}
+
+// Handle is called by the kafka consumer for each instance removed event. Any search index which could not be
+// deleted is left for the orphaned index collection to remove.
+func (h *InstanceRemovedHandler) Handle(ctx context.Context, workerID int, msg kafka.Message) error {
+	var event InstanceRemoved
+	if err := InstanceRemovedSchema.Unmarshal(msg.GetData(), &event); err != nil {
+		log.Error(ctx, "failed to unmarshal instance removed event", err)
+		return err
+	}
+
+	logData := log.Data{"instance_id": event.InstanceID, "reason": event.Reason, "worker_id": workerID}
+
+	if event.InstanceID == "" {
+		log.Error(ctx, "ignoring instance removed event", ErrMissingInstanceID, logData)
+		return ErrMissingInstanceID
+	}
+
+	deleted, err := h.DeleteInstanceIndexes(ctx, event.InstanceID)
+	logData["deleted"] = deleted
+	if err != nil {
+		log.Error(ctx, "failed to delete search indexes of removed instance", err, logData)
+		return err
+	}
+
+	log.Info(ctx, "deleted search indexes of removed instance", logData)
+	return nil
+}
+
+// DeleteInstanceIndexes deletes the search index of each dimension of an instance, returning the dimensions
+// whose search index was deleted
+func (h *InstanceRemovedHandler) DeleteInstanceIndexes(ctx context.Context, instanceID string) ([]string, error) {
+	indexes, err := h.searchIndexes.ListSearchIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := instanceID + "_"
+	deleted := []string{}
+
+	var lastErr error
+	for _, index := range indexes {
+		if !strings.HasPrefix(index, prefix) {
+			continue
+		}
+		dimension := strings.TrimPrefix(index, prefix)
+
+		if _, err := h.searchIndexes.DeleteSearchIndex(ctx, instanceID, dimension); err != nil {
+			if errors.Is(err, errs.ErrDeleteIndexNotFound) {
+				// already deleted, perhaps by a redelivered event
+				continue
+			}
+			log.Error(ctx, "failed to delete search index of removed instance", err, log.Data{"instance_id": instanceID, "dimension": dimension})
+			lastErr = err
+			continue
+		}
+		deleted = append(deleted, dimension)
+	}
+
+	return deleted, lastErr
+}
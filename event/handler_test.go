@@ -0,0 +1,71 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/mocks"
+	"github.com/ONSdigital/dp-kafka/v4/kafkatest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const removedInstanceID = "0b2d3b4e-1c2d-4e5f-8a9b-0c1d2e3f4a5b"
+
+func newMessage(event *InstanceRemoved) *kafkatest.Message {
+	data, err := InstanceRemovedSchema.Marshal(event)
+	So(err, ShouldBeNil)
+
+	msg, err := kafkatest.NewMessage(data, 0)
+	So(err, ShouldBeNil)
+
+	return msg
+}
+
+func TestInstanceRemovedHandler(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given search indexes exist for a removed instance and another instance", t, func() {
+		elasticsearch := &mocks.Elasticsearch{Indexes: []string{
+			removedInstanceID + "_aggregate",
+			removedInstanceID + "_geography",
+			"1c3e4c5f-2d3e-4f60-9bac-1d2e3f4a5b6c_aggregate",
+		}}
+		handler := NewInstanceRemovedHandler(elasticsearch)
+
+		Convey("When an instance removed event is handled only the search indexes of the removed instance are deleted", func() {
+			err := handler.Handle(ctx, 1, newMessage(&InstanceRemoved{InstanceID: removedInstanceID, Reason: ReasonDeleted}))
+			So(err, ShouldBeNil)
+			So(elasticsearch.Deleted, ShouldResemble, []string{removedInstanceID + "_aggregate", removedInstanceID + "_geography"})
+		})
+
+		Convey("When an instance removed event has no instance id nothing is deleted", func() {
+			err := handler.Handle(ctx, 1, newMessage(&InstanceRemoved{Reason: ReasonUnpublished}))
+			So(err, ShouldEqual, ErrMissingInstanceID)
+			So(elasticsearch.Deleted, ShouldBeEmpty)
+		})
+
+		Convey("When the search indexes have already been deleted the event is handled without error", func() {
+			elasticsearch.IndexNotFound = true
+			deleted, err := handler.DeleteInstanceIndexes(ctx, removedInstanceID)
+			So(err, ShouldBeNil)
+			So(deleted, ShouldBeEmpty)
+		})
+
+		Convey("When elasticsearch fails the error is returned", func() {
+			elasticsearch.InternalServerError = true
+			err := handler.Handle(ctx, 1, newMessage(&InstanceRemoved{InstanceID: removedInstanceID, Reason: ReasonDeleted}))
+			So(err, ShouldEqual, errs.ErrInternalServer)
+		})
+	})
+
+	Convey("Given a message which is not an instance removed event", t, func() {
+		handler := NewInstanceRemovedHandler(&mocks.Elasticsearch{})
+		msg, err := kafkatest.NewMessage([]byte("not avro"), 0)
+		So(err, ShouldBeNil)
+
+		Convey("When it is handled an error is returned", func() {
+			So(handler.Handle(ctx, 1, msg), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,30 @@
+// Package event handles the kafka events consumed by the dimension search API
+package event
+
+import "github.com/ONSdigital/dp-kafka/v4/avro"
+
+// Reasons an instance is removed
+const (
+	ReasonDeleted     = "deleted"
+	ReasonUnpublished = "unpublished"
+)
+
+var instanceRemovedSchema = `{
+  "type": "record",
+  "name": "instance-removed",
+  "fields": [
+    {"name": "instance_id", "type": "string"},
+    {"name": "reason", "type": "string", "default": ""}
+  ]
+}`
+
+// InstanceRemovedSchema is the avro schema for InstanceRemoved events
+var InstanceRemovedSchema = &avro.Schema{
+	Definition: instanceRemovedSchema,
+}
+
+// InstanceRemoved is sent when an instance has been deleted or unpublished, so it will not be searched against again
+type InstanceRemoved struct {
+	InstanceID string `avro:"instance_id"`
+	Reason     string `avro:"reason"`
+}
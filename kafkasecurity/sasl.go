@@ -0,0 +1,129 @@
+// Package kafkasecurity configures how the service authenticates with kafka using SASL,
+// which the kafka library does not support itself
+package kafkasecurity
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+
+	"github.com/ONSdigital/dp-kafka/v4/interfaces"
+	"github.com/Shopify/sarama"
+	"github.com/xdg-go/scram"
+)
+
+// SASL mechanisms which can be used to authenticate with kafka
+const (
+	MechanismPlain       = "PLAIN"
+	MechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	MechanismSCRAMSHA512 = "SCRAM-SHA-512"
+)
+
+// A list of errors returned when SASL is misconfigured
+var (
+	ErrMissingCredentials   = errors.New("kafka SASL username and password must both be set")
+	ErrPlainWithoutTLS      = errors.New("kafka SASL/PLAIN sends the password in the clear, so requires KAFKA_SEC_PROTO=TLS")
+	ErrUnsupportedMechanism = errors.New("unsupported kafka SASL mechanism, must be one of PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512")
+)
+
+// SASL holds the mechanism and credentials used to authenticate with kafka, and whether connections to
+// kafka are made over TLS
+type SASL struct {
+	Mechanism string
+	Username  string
+	Password  string
+	TLS       bool
+}
+
+// Validate checks the mechanism is supported, the credentials are set and, for PLAIN, which sends the
+// password as is, that connections are made over TLS
+func (s *SASL) Validate() error {
+	switch s.Mechanism {
+	case MechanismPlain, MechanismSCRAMSHA256, MechanismSCRAMSHA512:
+	default:
+		return ErrUnsupportedMechanism
+	}
+
+	if s.Username == "" || s.Password == "" {
+		return ErrMissingCredentials
+	}
+
+	if s.Mechanism == MechanismPlain && !s.TLS {
+		return ErrPlainWithoutTLS
+	}
+
+	return nil
+}
+
+// Apply configures a sarama config to authenticate with SASL
+func (s *SASL) Apply(config *sarama.Config) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	config.Net.SASL.Enable = true
+	config.Net.SASL.Handshake = true
+	config.Net.SASL.User = s.Username
+	config.Net.SASL.Password = s.Password
+
+	switch s.Mechanism {
+	case MechanismPlain:
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case MechanismSCRAMSHA256:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{hashGenerator: sha256.New} }
+	case MechanismSCRAMSHA512:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{hashGenerator: sha512.New} }
+	}
+
+	return nil
+}
+
+// ProducerInitialiser returns an initialiser for the kafka library's producer which authenticates with SASL.
+// The sarama config is shared with the producer's health check, so brokers it opens authenticate too.
+func (s *SASL) ProducerInitialiser() interfaces.ProducerInitialiser {
+	return func(addrs []string, config *sarama.Config) (sarama.AsyncProducer, error) {
+		if err := s.Apply(config); err != nil {
+			return nil, err
+		}
+		return sarama.NewAsyncProducer(addrs, config)
+	}
+}
+
+// ConsumerGroupInitialiser returns an initialiser for the kafka library's consumer group which authenticates
+// with SASL. The sarama config is shared with the consumer group's health check, so brokers it opens authenticate too.
+func (s *SASL) ConsumerGroupInitialiser() interfaces.ConsumerGroupInitialiser {
+	return func(addrs []string, groupID string, config *sarama.Config) (sarama.ConsumerGroup, error) {
+		if err := s.Apply(config); err != nil {
+			return nil, err
+		}
+		return sarama.NewConsumerGroup(addrs, groupID, config)
+	}
+}
+
+// scramClient carries out a SCRAM conversation with a kafka broker
+type scramClient struct {
+	conversation  *scram.ClientConversation
+	hashGenerator scram.HashGeneratorFcn
+}
+
+// Begin starts a SCRAM conversation for the user
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.hashGenerator.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.conversation = client.NewConversation()
+	return nil
+}
+
+// Step responds to a challenge from the broker
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+// Done reports whether the conversation has finished
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
@@ -0,0 +1,91 @@
+package kafkasecurity
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/xdg-go/scram"
+)
+
+func TestApply(t *testing.T) {
+	Convey("Given SASL/PLAIN credentials", t, func() {
+		sasl := &SASL{Mechanism: MechanismPlain, Username: "user", Password: "secret", TLS: true}
+
+		Convey("When applied to a sarama config the config authenticates with SASL/PLAIN", func() {
+			config := sarama.NewConfig()
+			So(sasl.Apply(config), ShouldBeNil)
+			So(config.Net.SASL.Enable, ShouldBeTrue)
+			So(config.Net.SASL.Mechanism, ShouldEqual, sarama.SASLMechanism(sarama.SASLTypePlaintext))
+			So(config.Net.SASL.User, ShouldEqual, "user")
+			So(config.Net.SASL.Password, ShouldEqual, "secret")
+			So(config.Validate(), ShouldBeNil)
+		})
+	})
+
+	Convey("Given SASL/SCRAM credentials", t, func() {
+		for mechanism, saslType := range map[string]sarama.SASLMechanism{
+			MechanismSCRAMSHA256: sarama.SASLTypeSCRAMSHA256,
+			MechanismSCRAMSHA512: sarama.SASLTypeSCRAMSHA512,
+		} {
+			sasl := &SASL{Mechanism: mechanism, Username: "user", Password: "secret"}
+
+			Convey("When applied to a sarama config the config authenticates with "+mechanism, func() {
+				config := sarama.NewConfig()
+				So(sasl.Apply(config), ShouldBeNil)
+				So(config.Net.SASL.Mechanism, ShouldEqual, saslType)
+				So(config.Net.SASL.SCRAMClientGeneratorFunc, ShouldNotBeNil)
+				So(config.Validate(), ShouldBeNil)
+			})
+		}
+	})
+
+	Convey("Given an unsupported mechanism an error is returned", t, func() {
+		sasl := &SASL{Mechanism: "GSSAPI", Username: "user", Password: "secret"}
+		So(sasl.Apply(sarama.NewConfig()), ShouldEqual, ErrUnsupportedMechanism)
+	})
+
+	Convey("Given SASL/PLAIN credentials without TLS an error is returned", t, func() {
+		sasl := &SASL{Mechanism: MechanismPlain, Username: "user", Password: "secret"}
+		So(sasl.Apply(sarama.NewConfig()), ShouldEqual, ErrPlainWithoutTLS)
+	})
+
+	Convey("Given a mechanism without a password an error is returned", t, func() {
+		sasl := &SASL{Mechanism: MechanismSCRAMSHA512, Username: "user"}
+		So(sasl.Apply(sarama.NewConfig()), ShouldEqual, ErrMissingCredentials)
+	})
+}
+
+func TestSCRAMClient(t *testing.T) {
+	Convey("Given a broker which knows the user's credentials", t, func() {
+		kf := scram.KeyFactors{Salt: "salt", Iters: 4096}
+		hashClient, err := scram.SHA512.NewClient("user", "secret", "")
+		So(err, ShouldBeNil)
+		credentials := hashClient.GetStoredCredentials(kf)
+
+		server, err := scram.SHA512.NewServer(func(string) (scram.StoredCredentials, error) { return credentials, nil })
+		So(err, ShouldBeNil)
+		serverConversation := server.NewConversation()
+
+		Convey("When the client carries out a conversation it authenticates", func() {
+			config := sarama.NewConfig()
+			So((&SASL{Mechanism: MechanismSCRAMSHA512, Username: "user", Password: "secret"}).Apply(config), ShouldBeNil)
+
+			client := config.Net.SASL.SCRAMClientGeneratorFunc()
+			So(client.Begin("user", "secret", ""), ShouldBeNil)
+
+			challenge := ""
+			for !client.Done() {
+				response, err := client.Step(challenge)
+				So(err, ShouldBeNil)
+				if client.Done() {
+					break
+				}
+				challenge, err = serverConversation.Step(response)
+				So(err, ShouldBeNil)
+			}
+
+			So(serverConversation.Valid(), ShouldBeTrue)
+		})
+	})
+}
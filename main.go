@@ -3,17 +3,24 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 
 	"github.com/ONSdigital/dp-api-clients-go/dataset"
+	"github.com/ONSdigital/dp-api-clients-go/hierarchy"
 	"github.com/ONSdigital/dp-api-clients-go/zebedee"
 	esauth "github.com/ONSdigital/dp-elasticsearch/v2/awsauth"
 	elastic "github.com/ONSdigital/dp-elasticsearch/v2/elasticsearch"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	dphttp "github.com/ONSdigital/dp-net/http"
 
+	"github.com/ONSdigital/dp-dimension-search-api/api"
 	"github.com/ONSdigital/dp-dimension-search-api/config"
 	"github.com/ONSdigital/dp-dimension-search-api/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-api/event"
+	"github.com/ONSdigital/dp-dimension-search-api/kafkasecurity"
+	"github.com/ONSdigital/dp-dimension-search-api/memorysearch"
+	"github.com/ONSdigital/dp-dimension-search-api/reconcile"
 	"github.com/ONSdigital/dp-dimension-search-api/searchoutputqueue"
 	"github.com/ONSdigital/dp-dimension-search-api/service"
 	kafka "github.com/ONSdigital/dp-kafka/v4"
@@ -44,15 +51,6 @@ func main() {
 	// sensitive fields are omitted from config.String().
 	log.Info(ctx, "config on startup", log.Data{"config": cfg})
 
-	var esSigner *esauth.Signer
-	if cfg.SignElasticsearchRequests {
-		esSigner, err = esauth.NewAwsSigner("", "", cfg.AwsRegion, cfg.AwsService)
-		if err != nil {
-			log.Error(ctx, "failed to create aws v4 signer", err)
-			os.Exit(1)
-		}
-	}
-
 	// Set up OpenTelemetry
 	otelConfig := dpotelgo.Config{
 		OtelServiceName:          cfg.OTServiceName,
@@ -70,49 +68,103 @@ func main() {
 	}()
 
 	elasticHTTPClient := dphttp.NewClient()
-	elasticsearch := elasticsearch.NewElasticSearchAPI(elasticHTTPClient, cfg.ElasticSearchAPIURL, cfg.SignElasticsearchRequests, esSigner, cfg.AwsService, cfg.AwsRegion)
 
-	pConfig := &kafka.ProducerConfig{
-		KafkaVersion:    &cfg.KafkaVersion,
-		MaxMessageBytes: &cfg.KafkaMaxBytes,
-		BrokerAddrs:     cfg.Brokers,
-		Topic:           cfg.HierarchyBuiltTopic,
+	var search searchBackend
+	var searchChecker healthcheck.Checker
+	var elasticAPI *elasticsearch.API
+	var elasticEndpoints []*elasticsearch.Endpoint
+	var esSigner *esauth.Signer
+	if cfg.ElasticSearchBackend == memorysearch.BackendMemory {
+		memoryIndex, err := memorysearch.Load(cfg.SearchFixturesPath)
+		exitIfError(ctx, err, "error loading search index fixtures")
+		search, searchChecker = memoryIndex, memoryIndex.Checker
+	} else {
+		elasticAPI, elasticEndpoints, esSigner = createElasticsearch(ctx, cfg)
+		search = elasticAPI
 	}
-	if cfg.KafkaSecProtocol == "TLS" {
-		pConfig.SecurityConfig = kafka.GetSecurityConfig(
-			cfg.KafkaSecCACerts,
-			cfg.KafkaSecClientCert,
-			cfg.KafkaSecClientKey,
-			cfg.KafkaSecSkipVerify,
-		)
+
+	var hierarchyBuiltProducer *kafka.Producer
+	var producerChecker healthcheck.Checker
+	var outputQueue api.OutputQueue
+	var outputQueueChecker healthcheck.Checker
+	var outbox *searchoutputqueue.Outbox
+
+	switch cfg.OutputQueueBackend {
+	case searchoutputqueue.BackendKafka:
+		hierarchyBuiltProducer, producerChecker, outputQueue, outputQueueChecker, outbox = createKafkaOutputQueue(ctx, cfg)
+	case searchoutputqueue.BackendMemory:
+		outputQueue = searchoutputqueue.CreateMemoryQueue()
+	case searchoutputqueue.BackendFile:
+		fileQueue, err := searchoutputqueue.CreateFileQueue(cfg.OutputQueueFilePath)
+		exitIfError(ctx, err, "error opening output queue file")
+		outputQueue = fileQueue
+	case searchoutputqueue.BackendWebhook:
+		if cfg.OutputQueueWebhookURL == "" {
+			log.Fatal(ctx, "no webhook to post search outputs to", errors.New("OUTPUT_QUEUE_WEBHOOK_URL must be set"))
+			os.Exit(1)
+		}
+		// a post which timed out may still have been received, so is not retried in case the search index is built twice
+		webhookHTTPClient := dphttp.NewClient()
+		webhookHTTPClient.SetMaxRetries(0)
+		outputQueue = searchoutputqueue.CreateWebhookQueue(webhookHTTPClient, cfg.OutputQueueWebhookURL)
+	default:
+		log.Fatal(ctx, "unknown output queue backend", errors.New("unknown output queue backend"), log.Data{"backend": cfg.OutputQueueBackend})
+		os.Exit(1)
 	}
-	hierarchyBuiltProducer, err := kafka.NewProducer(
-		ctx,
-		pConfig,
-	)
-	exitIfError(ctx, err, "error creating kafka hierarchyBuiltProducer")
+	log.Info(ctx, "search outputs will be queued", log.Data{"backend": cfg.OutputQueueBackend})
 
-	hierarchyBuiltProducer.LogErrors(ctx)
+	var priorityProducer *kafka.Producer
+	var priorityProducerChecker healthcheck.Checker
+	if cfg.HierarchyPriorityTopic != "" {
+		if cfg.OutputQueueBackend != searchoutputqueue.BackendKafka {
+			log.Fatal(ctx, "priority topic configured without kafka", errors.New("HIERARCHY_BUILT_PRIORITY_TOPIC requires the kafka output queue backend"))
+			os.Exit(1)
+		}
+		var priorityQueue api.OutputQueue
+		priorityProducer, priorityProducerChecker, priorityQueue = createPriorityOutputQueue(ctx, cfg)
+		outputQueue = searchoutputqueue.NewPriorityRouter(outputQueue, priorityQueue)
+	}
 
-	outputQueue := searchoutputqueue.CreateOutputQueue(hierarchyBuiltProducer.Channels().Output)
+	if cfg.BuildDedupWindow > 0 {
+		outputQueue = searchoutputqueue.NewDeduplicator(outputQueue, cfg.BuildDedupWindow)
+	}
 
 	datasetAPIClient := dataset.NewAPIClient(cfg.DatasetAPIURL)
 
-	hc := configureHealthChecks(ctx, cfg, elasticHTTPClient, esSigner, hierarchyBuiltProducer, datasetAPIClient)
+	hierarchyAPIClient := hierarchy.New(cfg.HierarchyAPIURL)
+
+	orphanCollector := reconcile.NewOrphanCollector(search, datasetAPIClient, cfg.ServiceAuthToken)
+	missingIndexReconciler := reconcile.NewMissingIndexReconciler(search, datasetAPIClient, hierarchyAPIClient, outputQueue, cfg.ServiceAuthToken, cfg.MissingIndexRebuildRate)
+
+	var instanceRemovedConsumer *kafka.ConsumerGroup
+	if cfg.InstanceRemovedConsumer {
+		instanceRemovedConsumer = createInstanceRemovedConsumer(ctx, cfg, event.NewInstanceRemovedHandler(search))
+	}
+
+	hc := configureHealthChecks(ctx, cfg, elasticHTTPClient, esSigner, elasticAPI, elasticEndpoints, searchChecker, producerChecker, priorityProducerChecker, instanceRemovedConsumer, outputQueueChecker, datasetAPIClient, hierarchyAPIClient)
 
 	svc := &service.Service{
 		AuthAPIURL:                cfg.AuthAPIURL,
 		BindAddr:                  cfg.BindAddr,
 		DatasetAPIClient:          datasetAPIClient,
 		DefaultMaxResults:         cfg.MaxSearchResultsOffset,
-		Elasticsearch:             elasticsearch,
+		Elasticsearch:             search,
 		ElasticsearchURL:          cfg.ElasticSearchAPIURL,
 		HasPrivateEndpoints:       cfg.HasPrivateEndpoints,
 		HealthCheck:               hc,
+		HierarchyAPIClient:        hierarchyAPIClient,
+		InstallIndexTemplate:      cfg.InstallIndexTemplate,
+		InstanceRemovedConsumer:   instanceRemovedConsumer,
 		MaxRetries:                cfg.MaxRetries,
+		MissingIndexReconciler:    missingIndexReconciler,
+		OrphanCollector:           orphanCollector,
+		OrphanedIndexGCDryRun:     cfg.OrphanedIndexGCDryRun,
+		OrphanedIndexGCInterval:   cfg.OrphanedIndexGCInterval,
+		Outbox:                    outbox,
 		OutputQueue:               outputQueue,
 		SearchAPIURL:              cfg.SearchAPIURL,
 		HierarchyBuiltProducer:    hierarchyBuiltProducer,
+		PriorityProducer:          priorityProducer,
 		OTServiceName:             cfg.OTServiceName,
 		ServiceAuthToken:          cfg.ServiceAuthToken,
 		Shutdown:                  cfg.GracefulShutdownTimeout,
@@ -123,12 +175,25 @@ func main() {
 	svc.Start(ctx)
 }
 
+// searchBackend is where search indexes are held, elasticsearch or, for local development, memory
+type searchBackend interface {
+	api.Elasticsearcher
+	reconcile.SearchIndexes
+}
+
 func configureHealthChecks(ctx context.Context,
 	cfg *config.Config,
 	elasticHTTPClient dphttp.Clienter,
 	esSigner *esauth.Signer,
-	producer *kafka.Producer,
-	datasetAPIClient *dataset.Client) *healthcheck.HealthCheck {
+	elasticAPI *elasticsearch.API,
+	elasticEndpoints []*elasticsearch.Endpoint,
+	searchChecker healthcheck.Checker,
+	producerChecker healthcheck.Checker,
+	priorityProducerChecker healthcheck.Checker,
+	consumer *kafka.ConsumerGroup,
+	outputQueueChecker healthcheck.Checker,
+	datasetAPIClient *dataset.Client,
+	hierarchyAPIClient *hierarchy.Client) *healthcheck.HealthCheck {
 	hasErrors := false
 
 	versionInfo, err := healthcheck.NewVersionInfo(BuildTime, GitCommit, Version)
@@ -144,18 +209,80 @@ func configureHealthChecks(ctx context.Context,
 		hasErrors = true
 	}
 
-	elasticClient := elastic.NewClientWithHTTPClientAndAwsSigner(cfg.ElasticSearchAPIURL, esSigner, cfg.SignElasticsearchRequests, elasticHTTPClient)
-	if err = hc.AddCheck("Elasticsearch", elasticClient.Checker); err != nil {
-		log.Error(ctx, "error creating elasticsearch health check", err)
-		hasErrors = true
+	for i, endpoint := range elasticEndpoints {
+		name := "Elasticsearch"
+		if i > 0 {
+			name = fmt.Sprintf("Elasticsearch %d", i+1)
+		}
+
+		// opensearch serverless collections have no cluster health API
+		elasticChecker := elastic.NewClientWithHTTPClientAndAwsSigner(endpoint.URL, esSigner, cfg.SignElasticsearchRequests, elasticHTTPClient).Checker
+		if cfg.ElasticSearchBackend == elasticsearch.BackendOpenSearchServerless {
+			elasticChecker = elasticAPI.Checker(endpoint)
+		}
+		if err = hc.AddCheck(name, endpoint.Checker(elasticChecker)); err != nil {
+			log.Error(ctx, "error creating elasticsearch health check", err, log.Data{"check": name})
+			hasErrors = true
+		}
+
+		if err = hc.AddCheck(name+" Circuit Breaker", endpoint.Breaker().Checker); err != nil {
+			log.Error(ctx, "error creating elasticsearch circuit breaker health check", err, log.Data{"check": name})
+			hasErrors = true
+		}
 	}
 
-	if err = hc.AddCheck("Kafka Producer", producer.Checker); err != nil {
-		log.Error(ctx, "error adding check for kafka producer", err)
-		hasErrors = true
+	// elasticsearch is not used when search indexes are held in memory
+	if elasticAPI != nil {
+		if err = hc.AddCheck("Elasticsearch Version", elasticAPI.VersionChecker); err != nil {
+			log.Error(ctx, "error creating elasticsearch version health check", err)
+			hasErrors = true
+		}
+	}
+
+	if searchChecker != nil {
+		if err = hc.AddCheck("Search Index", searchChecker); err != nil {
+			log.Error(ctx, "error creating search index health check", err)
+			hasErrors = true
+		}
+	}
+
+	// there is no producer when search outputs are queued without kafka
+	if producerChecker != nil {
+		if err = hc.AddCheck("Kafka Producer", producerChecker); err != nil {
+			log.Error(ctx, "error adding check for kafka producer", err)
+			hasErrors = true
+		}
+	}
+
+	if priorityProducerChecker != nil {
+		if err = hc.AddCheck("Kafka Priority Producer", priorityProducerChecker); err != nil {
+			log.Error(ctx, "error adding check for kafka priority producer", err)
+			hasErrors = true
+		}
+	}
+
+	if consumer != nil {
+		if err = hc.AddCheck("Kafka Consumer", consumer.Checker); err != nil {
+			log.Error(ctx, "error adding check for kafka consumer", err)
+			hasErrors = true
+		}
+	}
+
+	if outputQueueChecker != nil {
+		if err = hc.AddCheck("Search Output Queue", outputQueueChecker); err != nil {
+			log.Error(ctx, "error adding check for search output queue", err)
+			hasErrors = true
+		}
 	}
 
 	if cfg.HasPrivateEndpoints {
+		// the hierarchy API is used when validating requests to create a search index, which continue without
+		// it, and when reconciling missing search indexes, which are rebuilt on a later run, so it is not critical
+		if err = hc.AddCheck("Hierarchy API", nonCritical(hierarchyAPIClient.Checker)); err != nil {
+			log.Error(ctx, "error creating hierarchy API health check", err)
+			hasErrors = true
+		}
+
 		// zebedee is used only for identity checking
 		zebedeeClient := zebedee.New(cfg.AuthAPIURL)
 		if err = hc.AddCheck("Zebedee", zebedeeClient.Checker); err != nil {
@@ -171,6 +298,221 @@ func configureHealthChecks(ctx context.Context,
 	return &hc
 }
 
+// nonCritical returns a health check which warns, rather than failing, when checker is critical
+func nonCritical(checker healthcheck.Checker) healthcheck.Checker {
+	return func(ctx context.Context, state *healthcheck.CheckState) error {
+		if err := checker(ctx, state); err != nil {
+			return err
+		}
+		if state.Status() == healthcheck.StatusCritical {
+			return state.Update(healthcheck.StatusWarning, state.Message(), state.StatusCode())
+		}
+		return nil
+	}
+}
+
+// createElasticsearch returns the elasticsearch API, the clusters it reads searches from, and the signer for
+// requests to them, if they are signed
+func createElasticsearch(ctx context.Context, cfg *config.Config) (*elasticsearch.API, []*elasticsearch.Endpoint, *esauth.Signer) {
+	if err := elasticsearch.ValidateBackend(cfg.ElasticSearchBackend); err != nil {
+		log.Fatal(ctx, "invalid elasticsearch backend", err, log.Data{"backend": cfg.ElasticSearchBackend})
+		os.Exit(1)
+	}
+	awsService := elasticsearch.SigningService(cfg.ElasticSearchBackend, cfg.AwsService)
+
+	if err := elasticsearch.ValidateReadMode(cfg.ElasticSearchReadMode); err != nil {
+		log.Fatal(ctx, "invalid elasticsearch read mode", err, log.Data{"read_mode": cfg.ElasticSearchReadMode})
+		os.Exit(1)
+	}
+
+	// the primary cluster is first, every call other than a search is made to it
+	elasticURLs := append([]string{cfg.ElasticSearchAPIURL}, cfg.ElasticSearchSecondaryURLs...)
+	elasticEndpoints := make([]*elasticsearch.Endpoint, 0, len(elasticURLs))
+	for _, url := range elasticURLs {
+		breaker := elasticsearch.NewCircuitBreaker(cfg.BreakerFailures, cfg.BreakerErrorRate, cfg.BreakerWindow, cfg.BreakerCooldown)
+		elasticEndpoints = append(elasticEndpoints, elasticsearch.NewEndpoint(url, breaker))
+	}
+
+	var esSigner *esauth.Signer
+	var err error
+	if cfg.SignElasticsearchRequests {
+		esSigner, err = esauth.NewAwsSigner("", "", cfg.AwsRegion, awsService)
+		if err != nil {
+			log.Error(ctx, "failed to create aws v4 signer", err)
+			os.Exit(1)
+		}
+	}
+
+	// the elasticsearch API retries calls itself, so that request bodies are rewound and re-signed on each attempt
+	elasticAPIHTTPClient := dphttp.NewClient()
+	elasticAPIHTTPClient.SetMaxRetries(0)
+	elasticAPI := elasticsearch.NewElasticSearchAPI(elasticAPIHTTPClient, elasticEndpoints, cfg.ElasticSearchReadMode, cfg.ElasticSearchBackend, cfg.SignElasticsearchRequests, esSigner, awsService, cfg.AwsRegion, cfg.MaxRetries, cfg.ElasticSearchTimeout, cfg.RankingBoosts)
+
+	// searches use the default codec until the version is detected, which is tried again by the health check
+	if _, err = elasticAPI.DetectVersion(ctx); err != nil {
+		log.Warn(ctx, "elasticsearch version could not be detected at startup, searching with the default codec")
+	}
+
+	return elasticAPI, elasticEndpoints, esSigner
+}
+
+// createKafkaOutputQueue returns the hierarchy built producer, if one is needed, and its health check along
+// with an output queue which sends to it, either directly, with confirmed delivery or through an outbox
+func createKafkaOutputQueue(ctx context.Context, cfg *config.Config) (*kafka.Producer, healthcheck.Checker, api.OutputQueue, healthcheck.Checker, *searchoutputqueue.Outbox) {
+	pConfig := createProducerConfig(cfg, cfg.HierarchyBuiltTopic)
+
+	switch {
+	case cfg.OutboxPath != "":
+		// build requests are confirmed once written to the outbox, then relayed to kafka with confirmed delivery
+		confirmedOutputQueue := createConfirmedOutputQueue(ctx, cfg, pConfig)
+		outbox, err := searchoutputqueue.NewOutbox(cfg.OutboxPath, confirmedOutputQueue, cfg.OutboxRetryInterval)
+		exitIfError(ctx, err, "error opening outbox")
+		return nil, confirmedOutputQueue.Checker, outbox, outbox.Checker, outbox
+	case cfg.KafkaConfirmDelivery:
+		confirmedOutputQueue := createConfirmedOutputQueue(ctx, cfg, pConfig)
+		return nil, confirmedOutputQueue.Checker, confirmedOutputQueue, nil, nil
+	}
+
+	hierarchyBuiltProducer := createProducer(ctx, cfg, pConfig)
+	asyncOutputQueue := searchoutputqueue.CreateOutputQueue(hierarchyBuiltProducer.Channels().Output, cfg.KafkaEnqueueTimeout)
+
+	return hierarchyBuiltProducer, hierarchyBuiltProducer.Checker, asyncOutputQueue, asyncOutputQueue.Checker, nil
+}
+
+// createPriorityOutputQueue returns the priority producer, if one is needed, and its health check along with
+// an output queue which sends to it, either directly or with confirmed delivery. Urgent build requests skip
+// the outbox so they are not held behind its backlog.
+func createPriorityOutputQueue(ctx context.Context, cfg *config.Config) (*kafka.Producer, healthcheck.Checker, api.OutputQueue) {
+	pConfig := createProducerConfig(cfg, cfg.HierarchyPriorityTopic)
+
+	log.Info(ctx, "urgent search index build requests will be sent to the priority topic", log.Data{"topic": cfg.HierarchyPriorityTopic})
+
+	if cfg.KafkaConfirmDelivery || cfg.OutboxPath != "" {
+		confirmedOutputQueue := createConfirmedOutputQueue(ctx, cfg, pConfig)
+		return nil, confirmedOutputQueue.Checker, confirmedOutputQueue
+	}
+
+	priorityProducer := createProducer(ctx, cfg, pConfig)
+	return priorityProducer, priorityProducer.Checker, searchoutputqueue.CreateOutputQueue(priorityProducer.Channels().Output, cfg.KafkaEnqueueTimeout)
+}
+
+// createProducerConfig returns the config for a producer to topic
+func createProducerConfig(cfg *config.Config, topic string) *kafka.ProducerConfig {
+	pConfig := &kafka.ProducerConfig{
+		KafkaVersion:    &cfg.KafkaVersion,
+		MaxMessageBytes: &cfg.KafkaMaxBytes,
+		BrokerAddrs:     cfg.Brokers,
+		Topic:           topic,
+	}
+	if cfg.KafkaSecProtocol == "TLS" {
+		pConfig.SecurityConfig = kafka.GetSecurityConfig(
+			cfg.KafkaSecCACerts,
+			cfg.KafkaSecClientCert,
+			cfg.KafkaSecClientKey,
+			cfg.KafkaSecSkipVerify,
+		)
+	}
+
+	return pConfig
+}
+
+// createProducer returns an asynchronous producer with config pConfig
+func createProducer(ctx context.Context, cfg *config.Config, pConfig *kafka.ProducerConfig) *kafka.Producer {
+	var producer *kafka.Producer
+	var err error
+	if sasl := kafkaSASL(ctx, cfg); sasl != nil {
+		producer, err = kafka.NewProducerWithGenerators(ctx, pConfig, sasl.ProducerInitialiser(), kafka.SaramaNewBroker)
+	} else {
+		producer, err = kafka.NewProducer(ctx, pConfig)
+	}
+	exitIfError(ctx, err, "error creating kafka producer for "+pConfig.Topic)
+
+	producer.LogErrors(ctx)
+
+	return producer
+}
+
+// createConfirmedOutputQueue returns an output queue which waits for kafka to confirm delivery of each message,
+// using a synchronous producer with config pConfig
+func createConfirmedOutputQueue(ctx context.Context, cfg *config.Config, pConfig *kafka.ProducerConfig) *searchoutputqueue.ConfirmedOutput {
+	saramaConfig, err := pConfig.Get()
+	exitIfError(ctx, err, "error creating kafka config for confirmed delivery")
+
+	if sasl := kafkaSASL(ctx, cfg); sasl != nil {
+		err = sasl.Apply(saramaConfig)
+		exitIfError(ctx, err, "error configuring kafka SASL for confirmed delivery")
+	}
+
+	// a synchronous producer waits on successes and errors to confirm delivery
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Return.Errors = true
+
+	// connects on first use so the service can start while kafka is unavailable
+	producer := searchoutputqueue.NewSyncProducer(cfg.Brokers, saramaConfig, *pConfig.MinBrokersHealthy)
+
+	log.Info(ctx, "search index build requests will wait for kafka to confirm delivery", log.Data{"timeout": cfg.KafkaDeliveryTimeout.String()})
+
+	return searchoutputqueue.CreateConfirmedOutputQueue(producer, pConfig.Topic, cfg.KafkaDeliveryTimeout)
+}
+
+// createInstanceRemovedConsumer returns a consumer which deletes the search indexes of instances as they are
+// deleted or unpublished
+func createInstanceRemovedConsumer(ctx context.Context, cfg *config.Config, handler *event.InstanceRemovedHandler) *kafka.ConsumerGroup {
+	cgConfig := &kafka.ConsumerGroupConfig{
+		KafkaVersion: &cfg.KafkaVersion,
+		Topic:        cfg.InstanceRemovedTopic,
+		GroupName:    cfg.InstanceRemovedGroup,
+		BrokerAddrs:  cfg.Brokers,
+	}
+	if cfg.KafkaSecProtocol == "TLS" {
+		cgConfig.SecurityConfig = kafka.GetSecurityConfig(
+			cfg.KafkaSecCACerts,
+			cfg.KafkaSecClientCert,
+			cfg.KafkaSecClientKey,
+			cfg.KafkaSecSkipVerify,
+		)
+	}
+
+	var consumer *kafka.ConsumerGroup
+	var err error
+	if sasl := kafkaSASL(ctx, cfg); sasl != nil {
+		consumer, err = kafka.NewConsumerGroupWithGenerators(ctx, cgConfig, sasl.ConsumerGroupInitialiser(), kafka.SaramaNewBroker)
+	} else {
+		consumer, err = kafka.NewConsumerGroup(ctx, cgConfig)
+	}
+	exitIfError(ctx, err, "error creating kafka instance removed consumer")
+
+	err = consumer.RegisterHandler(ctx, handler.Handle)
+	exitIfError(ctx, err, "error registering instance removed handler")
+
+	consumer.LogErrors(ctx)
+
+	log.Info(ctx, "search indexes of removed instances will be deleted", log.Data{"topic": cfg.InstanceRemovedTopic, "group": cfg.InstanceRemovedGroup})
+
+	return consumer
+}
+
+// kafkaSASL returns the SASL mechanism and credentials to authenticate with kafka, or nil if SASL is not used
+func kafkaSASL(ctx context.Context, cfg *config.Config) *kafkasecurity.SASL {
+	if cfg.KafkaSASLMechanism == "" {
+		return nil
+	}
+
+	sasl := &kafkasecurity.SASL{
+		Mechanism: cfg.KafkaSASLMechanism,
+		Username:  cfg.KafkaSASLUsername,
+		Password:  cfg.KafkaSASLPassword,
+		TLS:       cfg.KafkaSecProtocol == "TLS",
+	}
+	exitIfError(ctx, sasl.Validate(), "invalid kafka SASL config")
+
+	if !sasl.TLS {
+		log.Warn(ctx, "kafka SASL is enabled without TLS, so messages and the SCRAM exchange are not encrypted", log.Data{"mechanism": sasl.Mechanism})
+	}
+
+	return sasl
+}
+
 func exitIfError(ctx context.Context, err error, message string) {
 	if err != nil {
 		log.Fatal(ctx, message, err)
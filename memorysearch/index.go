@@ -0,0 +1,292 @@
+// Package memorysearch is an in-process search backend, loaded from fixture files, so that the service can be
+// run locally and tested without elasticsearch
+package memorysearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/elasticsearch"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// BackendMemory selects the in-process search backend in place of elasticsearch
+const BackendMemory = "memory"
+
+// Highlight tags, the same as those elasticsearch is asked to wrap matches in
+const (
+	preTag  = "\u0001S"
+	postTag = "\u0001E"
+)
+
+// Index holds a search index, of the dimension options of an instance dimension, for each fixture file
+type Index struct {
+	mu      sync.RWMutex
+	indexes map[string]*searchIndex
+}
+
+// searchIndex is an inverted index of the tokens in the labels and codes of the dimension options
+type searchIndex struct {
+	options  []models.SearchResult
+	postings map[string][]int
+}
+
+// token is a lowercased word in a label or code, with its position in bytes
+type token struct {
+	text       string
+	start, end int
+}
+
+// New returns an empty in-process search backend
+func New() *Index {
+	return &Index{indexes: make(map[string]*searchIndex)}
+}
+
+// Load returns an in-process search backend with a search index for each json file in dir. Each file is named
+// {instanceID}_{dimension}.json and holds a list of dimension options, as they are returned by a search.
+func Load(dir string) (*Index, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	index := New()
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var options []models.SearchResult
+		if err = json.Unmarshal(b, &options); err != nil {
+			return nil, fmt.Errorf("invalid search index fixture %s: %w", path, err)
+		}
+
+		index.put(strings.TrimSuffix(filepath.Base(path), ".json"), options)
+	}
+
+	return index, nil
+}
+
+// Put replaces the search index of an instance dimension with options
+func (index *Index) Put(instanceID, dimension string, options []models.SearchResult) {
+	index.put(elasticsearch.AliasName(instanceID, dimension), options)
+}
+
+func (index *Index) put(name string, options []models.SearchResult) {
+	search := &searchIndex{options: options, postings: make(map[string][]int)}
+	for i, option := range options {
+		seen := make(map[string]bool)
+		for _, t := range append(tokenise(option.Label), tokenise(option.Code)...) {
+			if !seen[t.text] {
+				search.postings[t.text] = append(search.postings[t.text], i)
+				seen[t.text] = true
+			}
+		}
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.indexes[name] = search
+}
+
+// QuerySearchIndex returns the dimension options whose label or code contain any of the words in term, those
+// containing the most, and rarest, words first. Matched words are highlighted as they are by elasticsearch. The
+// total is always exact.
+func (index *Index) QuerySearchIndex(ctx context.Context, instanceID, dimension, term string, limit, offset int, _ bool) (*models.SearchResponse, int, error) {
+	name := elasticsearch.AliasName(instanceID, dimension)
+
+	index.mu.RLock()
+	search, ok := index.indexes[name]
+	index.mu.RUnlock()
+	if !ok {
+		log.Info(ctx, "search index not found in memory", log.Data{"index": name})
+		return nil, http.StatusNotFound, errs.ErrDimensionNotSearchable
+	}
+
+	terms := make(map[string]bool)
+	scores := make(map[int]float64)
+	for _, t := range tokenise(term) {
+		if terms[t.text] {
+			continue
+		}
+		terms[t.text] = true
+
+		matches := search.postings[t.text]
+		idf := 1 + math.Log(float64(len(search.options))/float64(len(matches)+1)+1)
+		for _, i := range matches {
+			scores[i] += idf
+		}
+	}
+
+	hits := make([]models.HitList, 0, len(scores))
+	for i, score := range scores {
+		option := search.options[i]
+		hits = append(hits, models.HitList{
+			Score:  score,
+			Source: option,
+			Highlight: models.Highlight{
+				Code:  highlight(option.Code, terms),
+				Label: highlight(option.Label, terms),
+			},
+		})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		return before(hits[i], hits[j])
+	})
+
+	return &models.SearchResponse{
+		Hits: models.Hits{
+			Total:   models.Total{Value: len(hits), Relation: models.TotalCountExact},
+			HitList: page(hits, limit, offset),
+		},
+	}, http.StatusOK, nil
+}
+
+// DeleteSearchIndex removes the search index of an instance dimension
+func (index *Index) DeleteSearchIndex(_ context.Context, instanceID, dimension string) (int, error) {
+	name := elasticsearch.AliasName(instanceID, dimension)
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if _, ok := index.indexes[name]; !ok {
+		return http.StatusNotFound, errs.ErrDeleteIndexNotFound
+	}
+	delete(index.indexes, name)
+
+	return http.StatusOK, nil
+}
+
+// ListSearchIndexes returns the names of the search indexes, in order
+func (index *Index) ListSearchIndexes(_ context.Context) ([]string, error) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	names := make([]string, 0, len(index.indexes))
+	for name := range index.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// SwapAlias always fails, as search indexes held in memory are not versioned
+func (index *Index) SwapAlias(_ context.Context, instanceID, dimension, target string, _ bool) (*models.AliasSwap, error) {
+	if !strings.HasPrefix(target, elasticsearch.AliasName(instanceID, dimension)+elasticsearch.VersionSeparator) {
+		return nil, errs.ErrInvalidAliasTarget
+	}
+	return nil, errs.ErrAliasTargetNotFound
+}
+
+// RollbackAlias always fails, as search indexes held in memory are not versioned
+func (index *Index) RollbackAlias(_ context.Context, _, _ string) (*models.AliasSwap, error) {
+	return nil, errs.ErrNoPreviousIndex
+}
+
+// CheckTemplate reports that the search indexes are as expected, as there is no index template in memory
+func (index *Index) CheckTemplate(_ context.Context) (*models.IndexTemplateReport, error) {
+	return &models.IndexTemplateReport{
+		Drift:            []models.MappingDrift{},
+		InstalledVersion: elasticsearch.TemplateVersion,
+		Name:             elasticsearch.TemplateName,
+		Version:          elasticsearch.TemplateVersion,
+	}, nil
+}
+
+// InstallTemplate does nothing, as there is no index template in memory
+func (index *Index) InstallTemplate(ctx context.Context) (*models.IndexTemplateReport, error) {
+	return index.CheckTemplate(ctx)
+}
+
+// Checker reports the number of search indexes held in memory
+func (index *Index) Checker(_ context.Context, state *healthcheck.CheckState) error {
+	index.mu.RLock()
+	count := len(index.indexes)
+	index.mu.RUnlock()
+
+	return state.Update(healthcheck.StatusOK, fmt.Sprintf("%d search indexes held in memory", count), http.StatusOK)
+}
+
+// before reports whether hit a is ordered before hit b, as elasticsearch orders indexes created from the index
+// template: by relevance, then by label, ignoring case, then by code, so that every page of a search is in the
+// same order
+func before(a, b models.HitList) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	if labelA, labelB := strings.ToLower(a.Source.Label), strings.ToLower(b.Source.Label); labelA != labelB {
+		return labelA < labelB
+	}
+	return a.Source.Code < b.Source.Code
+}
+
+// page returns the hits from offset, up to limit of them
+func page(hits []models.HitList, limit, offset int) []models.HitList {
+	if offset >= len(hits) {
+		return []models.HitList{}
+	}
+	hits = hits[offset:]
+	if limit >= 0 && limit < len(hits) {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// tokenise splits s into lowercased words of letters and numbers, as the standard elasticsearch analyser does
+func tokenise(s string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range s {
+		word := unicode.IsLetter(r) || unicode.IsNumber(r)
+		if word && start < 0 {
+			start = i
+		}
+		if !word && start >= 0 {
+			tokens = append(tokens, token{text: strings.ToLower(s[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{text: strings.ToLower(s[start:]), start: start, end: len(s)})
+	}
+	return tokens
+}
+
+// highlight returns s, with the words in terms wrapped in the highlight tags, as the single fragment elasticsearch
+// would return, or nil if none of the words in s are in terms
+func highlight(s string, terms map[string]bool) []string {
+	var b strings.Builder
+	matched := false
+	prev := 0
+	for _, t := range tokenise(s) {
+		if !terms[t.text] {
+			continue
+		}
+		b.WriteString(s[prev:t.start])
+		b.WriteString(preTag)
+		b.WriteString(s[t.start:t.end])
+		b.WriteString(postTag)
+		prev = t.end
+		matched = true
+	}
+	if !matched {
+		return nil
+	}
+	b.WriteString(s[prev:])
+
+	return []string{b.String()}
+}
@@ -0,0 +1,175 @@
+package memorysearch
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func codes(response *models.SearchResponse) []string {
+	codes := make([]string, 0, len(response.Hits.HitList))
+	for _, hit := range response.Hits.HitList {
+		codes = append(codes, hit.Source.Code)
+	}
+	return codes
+}
+
+func TestQuerySearchIndex(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given search indexes loaded from fixture files", t, func() {
+		index, err := Load("testdata")
+		So(err, ShouldBeNil)
+
+		Convey("When a search is made for several words", func() {
+			response, status, err := index.QuerySearchIndex(ctx, "123", "aggregate", "Water gas", 10, 0, false)
+
+			Convey("Then the options containing the most words are returned first", func() {
+				So(err, ShouldBeNil)
+				So(status, ShouldEqual, http.StatusOK)
+				So(response.Hits.Total, ShouldResemble, models.Total{Value: 3, Relation: models.TotalCountExact})
+				So(codes(response), ShouldResemble, []string{"cpih1dim1G40000", "cpih1dim1S40400", "cpih1dim1T40500"})
+				So(response.Hits.HitList[0].Score, ShouldBeGreaterThan, response.Hits.HitList[1].Score)
+			})
+
+			Convey("Then the matched words are highlighted as they are by elasticsearch", func() {
+				So(response.Hits.HitList[0].Highlight.Label, ShouldResemble, []string{"04 Housing, \u0001Swater\u0001E, electricity, \u0001Sgas\u0001E and other fuels"})
+				So(response.Hits.HitList[0].Highlight.Code, ShouldBeNil)
+			})
+		})
+
+		Convey("When a search is made for a code", func() {
+			response, _, err := index.QuerySearchIndex(ctx, "123", "aggregate", "cpih1dim1a0", 10, 0, false)
+
+			Convey("Then the option with the code is returned with its code highlighted", func() {
+				So(err, ShouldBeNil)
+				So(codes(response), ShouldResemble, []string{"cpih1dim1A0"})
+				So(response.Hits.HitList[0].Highlight.Code, ShouldResemble, []string{"\u0001Scpih1dim1A0\u0001E"})
+			})
+		})
+
+		Convey("When a page of results is requested", func() {
+			response, _, err := index.QuerySearchIndex(ctx, "123", "aggregate", "water gas", 1, 1, false)
+
+			Convey("Then only that page is returned, along with the total", func() {
+				So(err, ShouldBeNil)
+				So(response.Hits.Total.Value, ShouldEqual, 3)
+				So(codes(response), ShouldResemble, []string{"cpih1dim1S40400"})
+			})
+		})
+
+		Convey("When a page beyond the results is requested", func() {
+			response, _, err := index.QuerySearchIndex(ctx, "123", "aggregate", "water", 10, 20, false)
+
+			Convey("Then no results are returned", func() {
+				So(err, ShouldBeNil)
+				So(response.Hits.HitList, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a search is made against an index which was not loaded", func() {
+			_, status, err := index.QuerySearchIndex(ctx, "456", "aggregate", "water", 10, 0, false)
+
+			Convey("Then the index is not found", func() {
+				So(err, ShouldEqual, errs.ErrDimensionNotSearchable)
+				So(status, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestManageSearchIndexes(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a search index put into memory", t, func() {
+		index := New()
+		index.Put("123", "geography", []models.SearchResult{{Code: "E92000001", Label: "England"}})
+
+		Convey("Then it is listed and reported by the health check", func() {
+			names, err := index.ListSearchIndexes(ctx)
+			So(err, ShouldBeNil)
+			So(names, ShouldResemble, []string{"123_geography"})
+
+			state := healthcheck.NewCheckState("Search Index")
+			So(index.Checker(ctx, state), ShouldBeNil)
+			So(state.Status(), ShouldEqual, healthcheck.StatusOK)
+			So(state.Message(), ShouldEqual, "1 search indexes held in memory")
+		})
+
+		Convey("When it is deleted", func() {
+			status, err := index.DeleteSearchIndex(ctx, "123", "geography")
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, http.StatusOK)
+
+			Convey("Then it can no longer be searched or deleted", func() {
+				_, _, err = index.QuerySearchIndex(ctx, "123", "geography", "england", 10, 0, false)
+				So(err, ShouldEqual, errs.ErrDimensionNotSearchable)
+
+				status, err = index.DeleteSearchIndex(ctx, "123", "geography")
+				So(err, ShouldEqual, errs.ErrDeleteIndexNotFound)
+				So(status, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When its alias is swapped or rolled back", func() {
+			_, invalidErr := index.SwapAlias(ctx, "123", "geography", "456_geography.1", false)
+			_, swapErr := index.SwapAlias(ctx, "123", "geography", "123_geography.1", false)
+			_, rollbackErr := index.RollbackAlias(ctx, "123", "geography")
+
+			Convey("Then it fails, as search indexes in memory are not versioned", func() {
+				So(invalidErr, ShouldEqual, errs.ErrInvalidAliasTarget)
+				So(swapErr, ShouldEqual, errs.ErrAliasTargetNotFound)
+				So(rollbackErr, ShouldEqual, errs.ErrNoPreviousIndex)
+			})
+		})
+	})
+
+	Convey("Given a fixture file which is not a list of dimension options", t, func() {
+		dir := t.TempDir()
+		So(os.WriteFile(filepath.Join(dir, "123_aggregate.json"), []byte(`{"code":"A"}`), 0o600), ShouldBeNil)
+
+		Convey("When the fixtures are loaded", func() {
+			_, err := Load(dir)
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "123_aggregate.json")
+			})
+		})
+	})
+}
+
+func TestQuerySearchIndexPaging(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given options which are equally relevant, some with the same label in a different case", t, func() {
+		index := New()
+		index.Put("123", "geography", []models.SearchResult{
+			{Code: "E05", Label: "Ward"},
+			{Code: "E02", Label: "ward"},
+			{Code: "E04", Label: "WARD"},
+			{Code: "E01", Label: "Ward"},
+			{Code: "E03", Label: "a ward"},
+		})
+
+		Convey("When every page of a search is requested", func() {
+			var paged []string
+			for offset := 0; offset < 5; offset += 2 {
+				response, _, err := index.QuerySearchIndex(ctx, "123", "geography", "ward", 2, offset, false)
+				So(err, ShouldBeNil)
+				paged = append(paged, codes(response)...)
+			}
+
+			Convey("Then the pages do not overlap and are ordered by label, ignoring case, then code", func() {
+				So(paged, ShouldResemble, []string{"E03", "E01", "E02", "E04", "E05"})
+			})
+		})
+	})
+}
@@ -2,6 +2,11 @@ package mocks
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 
 	"github.com/ONSdigital/dp-api-clients-go/dataset"
 	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
@@ -11,13 +16,18 @@ import (
 type DatasetAPI struct {
 	InternalServerError bool
 	VersionNotFound     bool
+	DatasetNotFound     bool
+	EditionNotFound     bool
 	RequireAuth         bool
 	RequireNoAuth       bool
 	Calls               int
 	IsAuthenticated     bool
+	Instances           map[string]dataset.Instance
+	// BuildSearchIndexTasks are the states of the import tasks building search indexes, by dimension
+	BuildSearchIndexTasks map[string]string
 }
 
-// GetVersion represents the mocked version that queries the dataset API to get a version resource
+// GetVersion represents the mocked version that queries the dataset API to get a version resource, of instance 123
 func (api *DatasetAPI) GetVersion(_ context.Context, _, serviceAuthToken, _, _, _, _, _ string) (ver dataset.Version, err error) {
 	api.IsAuthenticated = serviceAuthToken != ""
 	isBadAuthExpectation := (api.RequireNoAuth && api.IsAuthenticated) || (api.RequireAuth && !api.IsAuthenticated)
@@ -25,25 +35,113 @@ func (api *DatasetAPI) GetVersion(_ context.Context, _, serviceAuthToken, _, _,
 
 	if api.InternalServerError {
 		if isBadAuthExpectation {
-			return ver, errs.ErrVersionNotFound
+			return ver, versionNotFound()
 		}
 		return ver, errs.ErrInternalServer
 	}
 
-	if api.VersionNotFound {
+	if api.VersionNotFound || api.DatasetNotFound || api.EditionNotFound {
 		if isBadAuthExpectation {
 			return ver, errs.ErrInternalServer
 		}
-		return ver, errs.ErrVersionNotFound
+		return ver, versionNotFound()
 	}
 
 	if isBadAuthExpectation {
-		return ver, errs.ErrVersionNotFound
+		return ver, versionNotFound()
 	}
 
+	ver.ID = "123"
+	if api.BuildSearchIndexTasks != nil {
+		ver.ImportTasks = &dataset.InstanceImportTasks{}
+		for dimension, state := range api.BuildSearchIndexTasks {
+			ver.ImportTasks.BuildSearchIndexTasks = append(ver.ImportTasks.BuildSearchIndexTasks, &dataset.BuildSearchIndexTask{DimensionName: dimension, State: state})
+		}
+	}
 	return
 }
 
+// Get represents the mocked version that queries the dataset API to get a dataset resource
+func (api *DatasetAPI) Get(_ context.Context, _, _, _, datasetID string) (dataset.DatasetDetails, error) {
+	if api.DatasetNotFound {
+		return dataset.DatasetDetails{}, notFound("dataset not found", "/datasets/"+datasetID)
+	}
+	return dataset.DatasetDetails{ID: datasetID}, nil
+}
+
+// GetEdition represents the mocked version that queries the dataset API to get an edition resource
+func (api *DatasetAPI) GetEdition(_ context.Context, _, _, _, datasetID, edition string) (dataset.Edition, error) {
+	if api.DatasetNotFound || api.EditionNotFound {
+		return dataset.Edition{}, notFound("edition not found", "/datasets/"+datasetID+"/editions/"+edition)
+	}
+	return dataset.Edition{Edition: edition}, nil
+}
+
+// GetInstance represents the mocked version that queries the dataset API to get an instance resource.
+// If no instances have been set on the mock, every instance exists with a single aggregate dimension.
+func (api *DatasetAPI) GetInstance(_ context.Context, _, _, _, instanceID string) (dataset.Instance, error) {
+	api.Calls++
+
+	if api.InternalServerError {
+		return dataset.Instance{}, errs.ErrInternalServer
+	}
+
+	if api.Instances == nil {
+		instance := dataset.Instance{}
+		instance.ID = instanceID
+		instance.Dimensions = []dataset.VersionDimension{{ID: "aggregate", Name: "aggregate"}}
+		return instance, nil
+	}
+
+	instance, ok := api.Instances[instanceID]
+	if !ok {
+		return instance, instanceNotFound(instanceID)
+	}
+
+	return instance, nil
+}
+
+// GetInstancesInBatches represents the mocked version that queries the dataset API for all instances in a given state
+func (api *DatasetAPI) GetInstancesInBatches(_ context.Context, _, _, _ string, vars url.Values, _, _ int) (dataset.Instances, error) {
+	api.Calls++
+
+	if api.InternalServerError {
+		return dataset.Instances{}, errs.ErrInternalServer
+	}
+
+	instances := dataset.Instances{Items: []dataset.Instance{}}
+	for id := range api.Instances {
+		instance := api.Instances[id]
+		if state := vars.Get("state"); state != "" && instance.State != state {
+			continue
+		}
+		instances.Items = append(instances.Items, instance)
+	}
+	sort.Slice(instances.Items, func(i, j int) bool { return instances.Items[i].ID < instances.Items[j].ID })
+
+	instances.Count = len(instances.Items)
+	instances.TotalCount = len(instances.Items)
+
+	return instances, nil
+}
+
+// notFound is the error the dataset API client returns when the dataset API responds that the resource at uri is not found
+func notFound(body, uri string) error {
+	resp := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+	return dataset.NewDatasetAPIResponse(resp, uri)
+}
+
+func versionNotFound() error {
+	return notFound("version not found", "/datasets/123/editions/2017/versions/1")
+}
+
+func instanceNotFound(instanceID string) error {
+	return notFound("instance not found", "/instances/"+instanceID)
+}
+
 // Healthcheck represents the mocked version of the healthcheck
 func (api *DatasetAPI) Healthcheck() (string, error) {
 	return "healthcheckID", nil
@@ -3,6 +3,8 @@ package mocks
 import (
 	"context"
 	"net/http"
+	"strings"
+	"time"
 
 	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
 	"github.com/ONSdigital/dp-dimension-search-api/models"
@@ -12,16 +14,33 @@ import (
 type Elasticsearch struct {
 	InternalServerError bool
 	IndexNotFound       bool
+	Unavailable         bool
+	TimedOut            bool
+	TotalCapped         bool
+	AliasTargetNotFound bool
+	LegacyIndex         bool
+	NoPreviousIndex     bool
+	TemplateDrift       bool
+	Indexes             []string
+	Deleted             []string
 }
 
 // QuerySearchIndex represents the mocked version of building a query and then calling elasticsearch index
-func (api *Elasticsearch) QuerySearchIndex(_ context.Context, _, _, _ string, _, _ int) (*models.SearchResponse, int, error) {
+func (api *Elasticsearch) QuerySearchIndex(_ context.Context, _, _, _ string, _, _ int, exactTotal bool) (*models.SearchResponse, int, error) {
 	if api.InternalServerError {
 		return nil, 0, errs.ErrInternalServer
 	}
 
 	if api.IndexNotFound {
-		return nil, http.StatusInternalServerError, errs.ErrIndexNotFound
+		return nil, http.StatusNotFound, errs.ErrDimensionNotSearchable
+	}
+
+	if api.Unavailable {
+		return nil, 0, errs.WithRetryAfter(errs.ErrElasticsearchUnavailable, 25*time.Second)
+	}
+
+	if api.TimedOut {
+		return nil, http.StatusGatewayTimeout, errs.ErrElasticsearchTimeout
 	}
 
 	firstHit := models.HitList{
@@ -53,19 +72,27 @@ func (api *Elasticsearch) QuerySearchIndex(_ context.Context, _, _, _ string, _,
 		},
 	}
 
+	total := models.Total{
+		Value:    22,
+		Relation: models.TotalCountExact,
+	}
+	if api.TotalCapped {
+		total = models.Total{Value: 10000, Relation: models.TotalCountLowerBound}
+		if exactTotal {
+			total = models.Total{Value: 12345, Relation: models.TotalCountExact}
+		}
+	}
+
 	return &models.SearchResponse{
 		Hits: models.Hits{
-			Total: models.Total{
-				Value:    22,
-				Relation: "eq",
-			},
+			Total:   total,
 			HitList: []models.HitList{firstHit, secondHit},
 		},
 	}, http.StatusOK, nil
 }
 
 // DeleteSearchIndex represents the mocked version that removes an index from elasticsearch
-func (api *Elasticsearch) DeleteSearchIndex(_ context.Context, _, _ string) (int, error) {
+func (api *Elasticsearch) DeleteSearchIndex(_ context.Context, instanceID, dimension string) (int, error) {
 	if api.InternalServerError {
 		return 0, errs.ErrInternalServer
 	}
@@ -74,5 +101,79 @@ func (api *Elasticsearch) DeleteSearchIndex(_ context.Context, _, _ string) (int
 		return http.StatusNotFound, errs.ErrDeleteIndexNotFound
 	}
 
+	api.Deleted = append(api.Deleted, instanceID+"_"+dimension)
+
 	return http.StatusOK, nil
 }
+
+// ListSearchIndexes represents the mocked version that lists the search indexes in elasticsearch
+func (api *Elasticsearch) ListSearchIndexes(_ context.Context) ([]string, error) {
+	if api.InternalServerError {
+		return nil, errs.ErrInternalServer
+	}
+
+	return api.Indexes, nil
+}
+
+// SwapAlias represents the mocked version that points an alias at a different index
+func (api *Elasticsearch) SwapAlias(_ context.Context, instanceID, dimension, index string, replaceLegacy bool) (*models.AliasSwap, error) {
+	if api.InternalServerError {
+		return nil, errs.ErrInternalServer
+	}
+
+	alias := instanceID + "_" + dimension
+	if !strings.HasPrefix(index, alias+".") {
+		return nil, errs.ErrInvalidAliasTarget
+	}
+
+	if api.AliasTargetNotFound {
+		return nil, errs.ErrAliasTargetNotFound
+	}
+
+	if api.LegacyIndex && !replaceLegacy {
+		return nil, errs.ErrLegacyIndexExists
+	}
+
+	return &models.AliasSwap{Alias: alias, Index: index, PreviousIndex: alias + ".1"}, nil
+}
+
+// RollbackAlias represents the mocked version that points an alias back at its previous index
+func (api *Elasticsearch) RollbackAlias(_ context.Context, instanceID, dimension string) (*models.AliasSwap, error) {
+	if api.InternalServerError {
+		return nil, errs.ErrInternalServer
+	}
+
+	if api.NoPreviousIndex {
+		return nil, errs.ErrNoPreviousIndex
+	}
+
+	alias := instanceID + "_" + dimension
+	return &models.AliasSwap{Alias: alias, Index: alias + ".1", PreviousIndex: alias + ".2"}, nil
+}
+
+// CheckTemplate represents the mocked version that compares the installed index template with the expected one
+func (api *Elasticsearch) CheckTemplate(_ context.Context) (*models.IndexTemplateReport, error) {
+	if api.InternalServerError {
+		return nil, errs.ErrInternalServer
+	}
+
+	report := &models.IndexTemplateReport{Name: "dimension-search", Version: 1, InstalledVersion: 1, Drift: []models.MappingDrift{}}
+	if api.TemplateDrift {
+		report.Drift = append(report.Drift, models.MappingDrift{Field: "label.keyword", Expected: "keyword normalizer=sortable"})
+	}
+
+	return report, nil
+}
+
+// InstallTemplate represents the mocked version that installs the expected index template
+func (api *Elasticsearch) InstallTemplate(_ context.Context) (*models.IndexTemplateReport, error) {
+	if api.InternalServerError {
+		return nil, errs.ErrInternalServer
+	}
+
+	api.TemplateDrift = false
+	report, _ := api.CheckTemplate(context.Background())
+	report.Updated = true
+
+	return report, nil
+}
@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ONSdigital/dp-api-clients-go/hierarchy"
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+)
+
+// HierarchyAPI represents the hierarchies held by a mocked hierarchy API, keyed by {instanceID}_{dimension}
+type HierarchyAPI struct {
+	InternalServerError bool
+	Hierarchies         map[string]bool
+}
+
+// GetRoot represents the mocked version that queries the hierarchy API for the root of a hierarchy
+func (api *HierarchyAPI) GetRoot(_ context.Context, instanceID, name string) (hierarchy.Model, error) {
+	if api.InternalServerError {
+		return hierarchy.Model{}, errs.ErrInternalServer
+	}
+
+	if !api.Hierarchies[instanceID+"_"+name] {
+		return hierarchy.Model{}, hierarchy.NewErrInvalidHierarchyAPIResponse(http.StatusOK, http.StatusNotFound, "/hierarchies/"+instanceID+"/"+name)
+	}
+
+	return hierarchy.Model{Label: name}, nil
+}
@@ -0,0 +1,73 @@
+package mocks
+
+import (
+	"context"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+)
+
+// OrphanCollector represents a list of error flags to set error in mocked orphan collector
+type OrphanCollector struct {
+	InternalServerError bool
+	DryRun              bool
+	Calls               int
+}
+
+// Collect represents the mocked version of finding and removing orphaned search indexes
+func (c *OrphanCollector) Collect(_ context.Context, dryRun bool) (*models.OrphanReport, error) {
+	c.Calls++
+	c.DryRun = dryRun
+
+	if c.InternalServerError {
+		return nil, errs.ErrInternalServer
+	}
+
+	orphan := models.OrphanIndex{
+		Dimension:  "aggregate",
+		Index:      "9a8e2b9b-1b4c-4e3a-8b6c-2f1c0f2e3d4a_aggregate",
+		InstanceID: "9a8e2b9b-1b4c-4e3a-8b6c-2f1c0f2e3d4a",
+		Reason:     "instance not found",
+		Deleted:    !dryRun,
+	}
+
+	report := &models.OrphanReport{
+		Checked: 1,
+		DryRun:  dryRun,
+		Orphans: []models.OrphanIndex{orphan},
+	}
+	if !dryRun {
+		report.Deleted = 1
+	}
+
+	return report, nil
+}
+
+// MissingIndexReconciler represents a list of error flags to set error in mocked missing index reconciler
+type MissingIndexReconciler struct {
+	InternalServerError bool
+	InProgress          bool
+	DryRun              bool
+	Calls               int
+}
+
+// Reconcile represents the mocked version of finding and rebuilding missing search indexes
+func (r *MissingIndexReconciler) Reconcile(_ context.Context, dryRun bool) (*models.MissingIndexReport, error) {
+	r.Calls++
+	r.DryRun = dryRun
+
+	if r.InternalServerError {
+		return nil, errs.ErrInternalServer
+	}
+
+	if r.InProgress && !dryRun {
+		return nil, errs.ErrReconciliationInProgress
+	}
+
+	return &models.MissingIndexReport{
+		DryRun:    dryRun,
+		Instances: 1,
+		Missing:   []models.MissingIndex{{Dimension: "aggregate", InstanceID: "9a8e2b9b-1b4c-4e3a-8b6c-2f1c0f2e3d4a"}},
+		Queued:    !dryRun,
+	}, nil
+}
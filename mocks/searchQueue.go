@@ -4,12 +4,18 @@ import (
 	"context"
 	"fmt"
 
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+
 	"github.com/ONSdigital/dp-dimension-search-api/searchoutputqueue"
 )
 
 // BuildSearch contains a flag indicating whether the message failed to go on queue
 type BuildSearch struct {
-	ReturnError bool
+	ReturnError    bool
+	DeliveryFailed bool
+	Unavailable    bool
+	HighPriority   bool
+	Queued         []searchoutputqueue.Search
 }
 
 // MessageData contains the unique identifiers for search message
@@ -19,9 +25,21 @@ type MessageData struct {
 }
 
 // Queue checks whether the filter job has errored
-func (bs *BuildSearch) Queue(_ context.Context, _ *searchoutputqueue.Search) error {
+func (bs *BuildSearch) Queue(_ context.Context, search *searchoutputqueue.Search) error {
 	if bs.ReturnError {
 		return fmt.Errorf("no message produced for hierarchy built")
 	}
+	if bs.Unavailable {
+		return errs.ErrQueueUnavailable
+	}
+	if bs.DeliveryFailed {
+		return errs.ErrQueueDeliveryFailed
+	}
+	bs.Queued = append(bs.Queued, *search)
 	return nil
 }
+
+// QueuesHighPriority reports whether high priority search outputs are queued separately
+func (bs *BuildSearch) QueuesHighPriority() bool {
+	return bs.HighPriority
+}
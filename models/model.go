@@ -1,15 +1,14 @@
 package models
 
 import (
-	"errors"
+	"net/http"
 	"strconv"
 
 	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
 )
 
 func ErrorMaximumOffsetReached(m int) error {
-	err := errors.New("the maximum offset has been reached, the offset cannot be more than " + strconv.Itoa(m))
-	return err
+	return errs.New(http.StatusBadRequest, "maximum_offset_reached", "the maximum offset has been reached, the offset cannot be more than "+strconv.Itoa(m))
 }
 
 type SearchResponseES6 struct {
@@ -46,13 +45,24 @@ type Highlight struct {
 	Label []string `json:"label,omitempty"`
 }
 
+// Relations of the total count of search results to the number of matches
+const (
+	// TotalCountExact is the relation of a total count which is the number of matches
+	TotalCountExact = "eq"
+
+	// TotalCountLowerBound is the relation of a total count which is fewer than the number of matches,
+	// as elasticsearch stopped counting them
+	TotalCountLowerBound = "gte"
+)
+
 // SearchResults represents a structure for a list of returned objects
 type SearchResults struct {
-	Count      int            `json:"count"`
-	Items      []SearchResult `json:"items"`
-	Limit      int            `json:"limit"`
-	Offset     int            `json:"offset"`
-	TotalCount int            `json:"totalcount"`
+	Count              int            `json:"count"`
+	Items              []SearchResult `json:"items"`
+	Limit              int            `json:"limit"`
+	Offset             int            `json:"offset"`
+	TotalCount         int            `json:"totalcount"`
+	TotalCountRelation string         `json:"totalcount_relation"`
 }
 
 // SearchResult represents data on a single item of search results
@@ -101,3 +111,98 @@ func (page *PageVariables) ValidateQueryParameters(term string) error {
 
 	return nil
 }
+
+// OrphanReport represents the outcome of a search for orphaned search indexes
+type OrphanReport struct {
+	Checked int           `json:"checked"`
+	Deleted int           `json:"deleted"`
+	DryRun  bool          `json:"dry_run"`
+	Orphans []OrphanIndex `json:"orphans"`
+	Skipped int           `json:"skipped"`
+}
+
+// OrphanIndex represents a search index whose instance is no longer searchable
+type OrphanIndex struct {
+	Deleted    bool   `json:"deleted"`
+	Dimension  string `json:"dimension"`
+	Index      string `json:"index"`
+	InstanceID string `json:"instance_id"`
+	Reason     string `json:"reason"`
+}
+
+// SearchIndexBuild represents a request to build a search index, which is coalesced if an identical
+// request was recently queued
+type SearchIndexBuild struct {
+	Coalesced  bool   `json:"coalesced"`
+	Dimension  string `json:"dimension"`
+	InstanceID string `json:"instance_id"`
+	Priority   string `json:"priority"`
+}
+
+// MissingIndexReport represents the outcome of a search for published dimensions without a search index
+type MissingIndexReport struct {
+	DryRun    bool           `json:"dry_run"`
+	Instances int            `json:"instances"`
+	Missing   []MissingIndex `json:"missing"`
+	Queued    bool           `json:"queued"`
+	Skipped   int            `json:"skipped"`
+}
+
+// MissingIndex represents a hierarchical dimension of a published instance which has no search index
+type MissingIndex struct {
+	Dimension  string `json:"dimension"`
+	InstanceID string `json:"instance_id"`
+}
+
+// AliasSwapRequest represents the body of a request to point the alias for an instance dimension at a different index
+type AliasSwapRequest struct {
+	Index              string `json:"index"`
+	ReplaceLegacyIndex bool   `json:"replace_legacy_index"`
+}
+
+// AliasSwap represents the outcome of pointing the alias for an instance dimension at a different index
+type AliasSwap struct {
+	Alias         string `json:"alias"`
+	Index         string `json:"index"`
+	PreviousIndex string `json:"previous_index,omitempty"`
+}
+
+// IndexTemplateReport represents how the installed index template, and the mappings of existing
+// dimension search indexes, differ from the expected template
+type IndexTemplateReport struct {
+	Drift            []MappingDrift `json:"drift"`
+	InstalledVersion int            `json:"installed_version,omitempty"`
+	Name             string         `json:"name"`
+	Updated          bool           `json:"updated"`
+	Version          int            `json:"version"`
+}
+
+// MappingDrift represents a field which is missing, or mapped differently to the expected template.
+// Index is empty when the drift is in the installed template itself.
+type MappingDrift struct {
+	Actual   string `json:"actual,omitempty"`
+	Expected string `json:"expected"`
+	Field    string `json:"field"`
+	Index    string `json:"index,omitempty"`
+}
+
+// TemplateDrifted reports whether the installed template differs from the expected template
+func (r *IndexTemplateReport) TemplateDrifted() bool {
+	for _, drift := range r.Drift {
+		if drift.Index == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// RankingBoost represents how the relevance of the dimension options of a dimension is adjusted by their place in
+// its hierarchy. Each boost is added to a multiplier of 1, so that a zero boost has no effect.
+type RankingBoost struct {
+	// Children boosts options by the log of their number of children, multiplied by Children
+	Children float64 `json:"number_of_children,omitempty"`
+	// HasData boosts options which have data by HasData
+	HasData float64 `json:"has_data,omitempty"`
+	// Depth boosts options at the root of the hierarchy by Depth, halving with each level below it
+	Depth float64 `json:"depth,omitempty"`
+}
@@ -1,9 +1,10 @@
 package models
 
 import (
-	"errors"
+	"net/http"
 	"testing"
 
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -38,13 +39,13 @@ func TestValidateQueryParameters(t *testing.T) {
 	Convey("Given the query term is empty return with an error", t, func() {
 		err := p.ValidateQueryParameters("")
 		So(err, ShouldNotBeEmpty)
-		So(err, ShouldResemble, errors.New("empty search term"))
+		So(err, ShouldEqual, errs.ErrEmptySearchTerm)
 	})
 
 	Convey("Given the query term is NOT empty and the offset exceeds the maximum number of results return with an error", t, func() {
 		p.Limit = 30
 		p.Offset = 1200
 		err := p.ValidateQueryParameters("term")
-		So(err, ShouldResemble, errors.New("the maximum offset has been reached, the offset cannot be more than 1000"))
+		So(err, ShouldResemble, errs.New(http.StatusBadRequest, "maximum_offset_reached", "the maximum offset has been reached, the offset cannot be more than 1000"))
 	})
 }
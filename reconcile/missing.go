@@ -0,0 +1,187 @@
+package reconcile
+
+import (
+	"context"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/ONSdigital/dp-api-clients-go/hierarchy"
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	"github.com/ONSdigital/dp-dimension-search-api/searchoutputqueue"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+const (
+	statePublished = "published"
+
+	instanceBatchSize  = 100
+	instanceMaxWorkers = 2
+)
+
+// HierarchyAPIClient - An interface used to check whether a dimension is hierarchical
+type HierarchyAPIClient interface {
+	GetRoot(ctx context.Context, instanceID, name string) (hierarchy.Model, error)
+}
+
+// OutputQueue - An interface used to queue search index builds
+type OutputQueue interface {
+	Queue(ctx context.Context, output *searchoutputqueue.Search) error
+}
+
+// MissingIndexReconciler finds hierarchical dimensions of published instances that
+// have no search index, and queues the indexes to be rebuilt
+type MissingIndexReconciler struct {
+	datasetAPIClient   DatasetAPIClient
+	hierarchyAPIClient HierarchyAPIClient
+	inProgress         atomic.Bool
+	outputQueue        OutputQueue
+	rebuildInterval    time.Duration
+	rebuilds           chan []models.MissingIndex
+	searchIndexes      SearchIndexes
+	serviceAuthToken   string
+}
+
+// NewMissingIndexReconciler creates a MissingIndexReconciler which queues at most
+// rebuildsPerMinute index builds, or builds without delay if rebuildsPerMinute is not positive
+func NewMissingIndexReconciler(searchIndexes SearchIndexes, datasetAPIClient DatasetAPIClient, hierarchyAPIClient HierarchyAPIClient,
+	outputQueue OutputQueue, serviceAuthToken string, rebuildsPerMinute int) *MissingIndexReconciler {
+	var rebuildInterval time.Duration
+	if rebuildsPerMinute > 0 {
+		rebuildInterval = time.Minute / time.Duration(rebuildsPerMinute)
+	}
+
+	return &MissingIndexReconciler{
+		datasetAPIClient:   datasetAPIClient,
+		hierarchyAPIClient: hierarchyAPIClient,
+		outputQueue:        outputQueue,
+		rebuildInterval:    rebuildInterval,
+		rebuilds:           make(chan []models.MissingIndex, 1),
+		searchIndexes:      searchIndexes,
+		serviceAuthToken:   serviceAuthToken,
+	}
+}
+
+// Reconcile finds every hierarchical dimension of a published instance which has no search
+// index. Unless dryRun is set, the missing indexes are handed to Run to be rebuilt, or
+// ErrReconciliationInProgress is returned while Run is still queueing a previous reconciliation's rebuilds.
+func (r *MissingIndexReconciler) Reconcile(ctx context.Context, dryRun bool) (*models.MissingIndexReport, error) {
+	indexes, err := r.searchIndexes.ListSearchIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool, len(indexes))
+	for _, index := range indexes {
+		existing[index] = true
+	}
+
+	vars := url.Values{}
+	vars.Set("state", statePublished)
+
+	instances, err := r.datasetAPIClient.GetInstancesInBatches(ctx, "", r.serviceAuthToken, "", vars, instanceBatchSize, instanceMaxWorkers)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.MissingIndexReport{
+		DryRun:  dryRun,
+		Missing: []models.MissingIndex{},
+	}
+
+	for i := range instances.Items {
+		instance := &instances.Items[i]
+		report.Instances++
+
+		for _, dimension := range instance.Dimensions {
+			logData := log.Data{"instance_id": instance.ID, "dimension": dimension.Name}
+
+			if existing[instance.ID+"_"+dimension.Name] {
+				continue
+			}
+
+			hierarchical, err := r.isHierarchical(ctx, instance.ID, dimension.Name)
+			if err != nil {
+				log.Error(ctx, "missing index reconciliation: failed to check dimension hierarchy, skipping dimension", err, logData)
+				report.Skipped++
+				continue
+			}
+			if !hierarchical {
+				continue
+			}
+
+			log.Info(ctx, "missing index reconciliation: found missing search index", logData)
+			report.Missing = append(report.Missing, models.MissingIndex{
+				Dimension:  dimension.Name,
+				InstanceID: instance.ID,
+			})
+		}
+	}
+
+	if dryRun || len(report.Missing) == 0 {
+		return report, nil
+	}
+
+	// cleared by Run once every rebuild has been queued, so rebuilds are never queued twice
+	if !r.inProgress.CompareAndSwap(false, true) {
+		return nil, errs.ErrReconciliationInProgress
+	}
+	r.rebuilds <- report.Missing
+	report.Queued = true
+
+	return report, nil
+}
+
+// Run queues the rebuilds found by Reconcile, no faster than the configured rate,
+// until the context is cancelled. Another reconciliation is accepted once every rebuild has been queued.
+func (r *MissingIndexReconciler) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case missing := <-r.rebuilds:
+			queued := r.rebuild(ctx, missing)
+			r.inProgress.Store(false)
+			log.Info(ctx, "missing index reconciliation: rebuilds queued", log.Data{"missing": len(missing), "queued": queued})
+		}
+	}
+}
+
+func (r *MissingIndexReconciler) rebuild(ctx context.Context, missing []models.MissingIndex) (queued int) {
+	for i, index := range missing {
+		if i > 0 && r.rebuildInterval > 0 {
+			select {
+			case <-ctx.Done():
+				return queued
+			case <-time.After(r.rebuildInterval):
+			}
+		}
+
+		logData := log.Data{"instance_id": index.InstanceID, "dimension": index.Dimension}
+
+		output := &searchoutputqueue.Search{
+			Dimension:  index.Dimension,
+			InstanceID: index.InstanceID,
+		}
+		if err := r.outputQueue.Queue(ctx, output); err != nil {
+			log.Error(ctx, "missing index reconciliation: failed to queue search index build", err, logData)
+			continue
+		}
+		queued++
+	}
+
+	return queued
+}
+
+// isHierarchical reports whether the hierarchy API holds a hierarchy for the dimension
+func (r *MissingIndexReconciler) isHierarchical(ctx context.Context, instanceID, dimension string) (bool, error) {
+	if _, err := r.hierarchyAPIClient.GetRoot(ctx, instanceID, dimension); err != nil {
+		if errs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,153 @@
+package reconcile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-api-clients-go/dataset"
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/mocks"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	"github.com/ONSdigital/dp-dimension-search-api/searchoutputqueue"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const unpublishedInstanceID = "3e5a6e71-4f50-4182-bdce-3f4a5b6c7d8e"
+
+func setupReconciler(hierarchyAPI *mocks.HierarchyAPI) (*MissingIndexReconciler, *mocks.BuildSearch) {
+	elasticsearch := &mocks.Elasticsearch{Indexes: []string{publishedInstanceID + "_aggregate"}}
+	datasetAPI := &mocks.DatasetAPI{Instances: map[string]dataset.Instance{
+		publishedInstanceID:   newInstance(publishedInstanceID, "published", "aggregate", "geography", "time"),
+		unpublishedInstanceID: newInstance(unpublishedInstanceID, "associated", "aggregate"),
+	}}
+	outputQueue := &mocks.BuildSearch{}
+
+	return NewMissingIndexReconciler(elasticsearch, datasetAPI, hierarchyAPI, outputQueue, "token", 0), outputQueue
+}
+
+func TestReconcile(t *testing.T) {
+	Convey("Given a published instance with a hierarchical dimension that has no search index", t, func() {
+		hierarchyAPI := &mocks.HierarchyAPI{Hierarchies: map[string]bool{
+			publishedInstanceID + "_aggregate":   true,
+			publishedInstanceID + "_geography":   true,
+			unpublishedInstanceID + "_aggregate": true,
+		}}
+		reconciler, outputQueue := setupReconciler(hierarchyAPI)
+
+		Convey("When reconciled in dry-run mode", func() {
+			report, err := reconciler.Reconcile(context.Background(), true)
+
+			Convey("Then only the missing hierarchical dimension of the published instance is reported", func() {
+				So(err, ShouldBeNil)
+				So(report.DryRun, ShouldBeTrue)
+				So(report.Instances, ShouldEqual, 1)
+				So(report.Queued, ShouldBeFalse)
+				So(report.Missing, ShouldResemble, []models.MissingIndex{{Dimension: "geography", InstanceID: publishedInstanceID}})
+				So(reconciler.rebuilds, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When reconciled with dry-run mode disabled", func() {
+			report, err := reconciler.Reconcile(context.Background(), false)
+			So(err, ShouldBeNil)
+			So(report.Queued, ShouldBeTrue)
+
+			Convey("Then a second reconciliation is rejected until the rebuilds have been queued", func() {
+				report, err = reconciler.Reconcile(context.Background(), false)
+				So(err, ShouldEqual, errs.ErrReconciliationInProgress)
+				So(report, ShouldBeNil)
+			})
+
+			Convey("Then the rebuild is handed over to be queued", func() {
+				queued := reconciler.rebuild(context.Background(), <-reconciler.rebuilds)
+				So(queued, ShouldEqual, 1)
+				So(outputQueue.Queued, ShouldResemble, []searchoutputqueue.Search{{Dimension: "geography", InstanceID: publishedInstanceID}})
+			})
+		})
+	})
+
+	Convey("Given the hierarchy API is unavailable", t, func() {
+		reconciler, _ := setupReconciler(&mocks.HierarchyAPI{InternalServerError: true})
+
+		Convey("When reconciled the dimensions are skipped rather than rebuilt", func() {
+			report, err := reconciler.Reconcile(context.Background(), false)
+			So(err, ShouldBeNil)
+			So(report.Skipped, ShouldEqual, 2)
+			So(report.Missing, ShouldBeEmpty)
+			So(report.Queued, ShouldBeFalse)
+		})
+	})
+}
+
+// blockingQueue signals each search output it is asked to queue, then waits to be released before queueing it
+type blockingQueue struct {
+	queueing chan searchoutputqueue.Search
+	release  chan struct{}
+}
+
+func (q *blockingQueue) Queue(_ context.Context, output *searchoutputqueue.Search) error {
+	q.queueing <- *output
+	<-q.release
+	return nil
+}
+
+func TestReconcileWhileRunning(t *testing.T) {
+	Convey("Given a reconciler whose rebuilds are being queued", t, func() {
+		elasticsearch := &mocks.Elasticsearch{Indexes: []string{publishedInstanceID + "_aggregate"}}
+		datasetAPI := &mocks.DatasetAPI{Instances: map[string]dataset.Instance{
+			publishedInstanceID: newInstance(publishedInstanceID, "published", "aggregate", "geography"),
+		}}
+		hierarchyAPI := &mocks.HierarchyAPI{Hierarchies: map[string]bool{publishedInstanceID + "_geography": true}}
+		outputQueue := &blockingQueue{queueing: make(chan searchoutputqueue.Search), release: make(chan struct{})}
+		reconciler := NewMissingIndexReconciler(elasticsearch, datasetAPI, hierarchyAPI, outputQueue, "token", 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go reconciler.Run(ctx)
+
+		report, err := reconciler.Reconcile(ctx, false)
+		So(err, ShouldBeNil)
+		So(report.Queued, ShouldBeTrue)
+		So(<-outputQueue.queueing, ShouldResemble, searchoutputqueue.Search{Dimension: "geography", InstanceID: publishedInstanceID})
+
+		Convey("When reconciled again before the rebuilds have been queued it is rejected", func() {
+			report, err = reconciler.Reconcile(ctx, false)
+			So(err, ShouldEqual, errs.ErrReconciliationInProgress)
+			So(report, ShouldBeNil)
+
+			Convey("And once the rebuilds have been queued another reconciliation is accepted", func() {
+				close(outputQueue.release)
+				for deadline := time.Now().Add(time.Second); reconciler.inProgress.Load() && time.Now().Before(deadline); {
+					time.Sleep(time.Millisecond)
+				}
+
+				report, err = reconciler.Reconcile(ctx, false)
+				So(err, ShouldBeNil)
+				So(report.Queued, ShouldBeTrue)
+				So(<-outputQueue.queueing, ShouldResemble, searchoutputqueue.Search{Dimension: "geography", InstanceID: publishedInstanceID})
+			})
+		})
+	})
+}
+
+func TestRebuildRate(t *testing.T) {
+	Convey("Given a reconciler limited to 600 rebuilds per minute", t, func() {
+		outputQueue := &mocks.BuildSearch{}
+		reconciler := NewMissingIndexReconciler(&mocks.Elasticsearch{}, &mocks.DatasetAPI{}, &mocks.HierarchyAPI{}, outputQueue, "token", 600)
+		So(reconciler.rebuildInterval, ShouldEqual, 100*time.Millisecond)
+
+		Convey("When three rebuilds are queued, they are spaced out by the rebuild interval", func() {
+			missing := []models.MissingIndex{
+				{Dimension: "aggregate", InstanceID: publishedInstanceID},
+				{Dimension: "geography", InstanceID: publishedInstanceID},
+				{Dimension: "time", InstanceID: publishedInstanceID},
+			}
+
+			start := time.Now()
+			queued := reconciler.rebuild(context.Background(), missing)
+			So(queued, ShouldEqual, 3)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 200*time.Millisecond)
+		})
+	})
+}
@@ -0,0 +1,176 @@
+package reconcile
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/ONSdigital/dp-api-clients-go/dataset"
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-dimension-search-api/models"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// Instance states which mean an instance will never be searched against again
+const (
+	stateDetached = "detached"
+	stateFailed   = "failed"
+)
+
+// Reasons recorded against an orphaned index
+const (
+	reasonInstanceNotFound  = "instance not found"
+	reasonInstanceDetached  = "instance has been superseded"
+	reasonInstanceFailed    = "instance failed to import"
+	reasonDimensionNotFound = "dimension not found on instance"
+)
+
+// searchIndexPattern matches index names of the form {instanceID}_{dimension}, where
+// the instance ID is a UUID, so that indexes belonging to other services are never touched
+var searchIndexPattern = regexp.MustCompile(`^([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})_(.+)$`)
+
+// OrphanCollector finds, and optionally removes, search indexes whose instance
+// is no longer searchable
+type OrphanCollector struct {
+	datasetAPIClient DatasetAPIClient
+	searchIndexes    SearchIndexes
+	serviceAuthToken string
+}
+
+// NewOrphanCollector creates an OrphanCollector
+func NewOrphanCollector(searchIndexes SearchIndexes, datasetAPIClient DatasetAPIClient, serviceAuthToken string) *OrphanCollector {
+	return &OrphanCollector{
+		datasetAPIClient: datasetAPIClient,
+		searchIndexes:    searchIndexes,
+		serviceAuthToken: serviceAuthToken,
+	}
+}
+
+// Collect checks every search index against the state of its instance. Orphaned
+// indexes are always reported and are only deleted when dryRun is false.
+func (c *OrphanCollector) Collect(ctx context.Context, dryRun bool) (*models.OrphanReport, error) {
+	indexes, err := c.searchIndexes.ListSearchIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.OrphanReport{
+		DryRun:  dryRun,
+		Orphans: []models.OrphanIndex{},
+	}
+
+	// cache instances as most have more than one searchable dimension
+	instances := make(map[string]*dataset.Instance)
+
+	for _, index := range indexes {
+		matches := searchIndexPattern.FindStringSubmatch(index)
+		if matches == nil {
+			continue
+		}
+		report.Checked++
+
+		instanceID, dimension := matches[1], matches[2]
+		logData := log.Data{"index": index, "instance_id": instanceID, "dimension": dimension}
+
+		instance, ok := instances[instanceID]
+		if !ok {
+			instance, err = c.getInstance(ctx, instanceID)
+			if err != nil {
+				// never treat an index as orphaned when its state is unknown
+				log.Error(ctx, "orphaned index collection: failed to get instance, skipping index", err, logData)
+				report.Skipped++
+				continue
+			}
+			instances[instanceID] = instance
+		}
+
+		reason := orphanReason(instance, dimension)
+		if reason == "" {
+			continue
+		}
+
+		orphan := models.OrphanIndex{
+			Index:      index,
+			InstanceID: instanceID,
+			Dimension:  dimension,
+			Reason:     reason,
+		}
+		logData["reason"] = reason
+
+		if !dryRun {
+			if _, err := c.searchIndexes.DeleteSearchIndex(ctx, instanceID, dimension); err != nil {
+				log.Error(ctx, "orphaned index collection: failed to delete index", err, logData)
+				report.Orphans = append(report.Orphans, orphan)
+				continue
+			}
+			orphan.Deleted = true
+			report.Deleted++
+		}
+
+		log.Info(ctx, "orphaned index collection: found orphaned index", logData)
+		report.Orphans = append(report.Orphans, orphan)
+	}
+
+	return report, nil
+}
+
+// Run collects orphaned indexes every interval until the context is cancelled
+func (c *OrphanCollector) Run(ctx context.Context, interval time.Duration, dryRun bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := c.Collect(ctx, dryRun)
+			if err != nil {
+				log.Error(ctx, "orphaned index collection: failed to list search indexes", err)
+				continue
+			}
+			log.Info(ctx, "orphaned index collection: complete", log.Data{
+				"dry_run": report.DryRun,
+				"checked": report.Checked,
+				"orphans": len(report.Orphans),
+				"deleted": report.Deleted,
+				"skipped": report.Skipped,
+			})
+		}
+	}
+}
+
+// getInstance returns the instance, or nil if the dataset API does not know about it
+func (c *OrphanCollector) getInstance(ctx context.Context, instanceID string) (*dataset.Instance, error) {
+	instance, err := c.datasetAPIClient.GetInstance(ctx, "", c.serviceAuthToken, "", instanceID)
+	if err != nil {
+		if errs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &instance, nil
+}
+
+func orphanReason(instance *dataset.Instance, dimension string) string {
+	switch {
+	case instance == nil:
+		return reasonInstanceNotFound
+	case instance.State == stateDetached:
+		return reasonInstanceDetached
+	case instance.State == stateFailed:
+		return reasonInstanceFailed
+	case len(instance.Dimensions) == 0:
+		// dimensions have not been recorded against the instance yet
+		return ""
+	}
+
+	for i := range instance.Dimensions {
+		if instance.Dimensions[i].Name == dimension || instance.Dimensions[i].ID == dimension {
+			return ""
+		}
+	}
+
+	return reasonDimensionNotFound
+}
@@ -0,0 +1,109 @@
+package reconcile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ONSdigital/dp-api-clients-go/dataset"
+	"github.com/ONSdigital/dp-dimension-search-api/mocks"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	publishedInstanceID = "0b2d3b4e-1c2d-4e5f-8a9b-0c1d2e3f4a5b"
+	detachedInstanceID  = "1c3e4c5f-2d3e-4f60-9bac-1d2e3f4a5b6c"
+	deletedInstanceID   = "2d4f5d60-3e4f-4071-acbd-2e3f4a5b6c7d"
+)
+
+func newInstance(id, state string, dimensions ...string) dataset.Instance {
+	instance := dataset.Instance{}
+	instance.ID = id
+	instance.State = state
+	for _, dimension := range dimensions {
+		instance.Dimensions = append(instance.Dimensions, dataset.VersionDimension{ID: dimension, Name: dimension})
+	}
+	return instance
+}
+
+func setupCollector() (*OrphanCollector, *mocks.Elasticsearch) {
+	elasticsearch := &mocks.Elasticsearch{Indexes: []string{
+		publishedInstanceID + "_aggregate",
+		publishedInstanceID + "_geography",
+		detachedInstanceID + "_aggregate",
+		deletedInstanceID + "_aggregate",
+		"ons_201906041200",
+	}}
+	datasetAPI := &mocks.DatasetAPI{Instances: map[string]dataset.Instance{
+		publishedInstanceID: newInstance(publishedInstanceID, "published", "aggregate", "time"),
+		detachedInstanceID:  newInstance(detachedInstanceID, "detached", "aggregate"),
+	}}
+
+	return NewOrphanCollector(elasticsearch, datasetAPI, "token"), elasticsearch
+}
+
+func TestCollect(t *testing.T) {
+	Convey("Given a cluster containing orphaned search indexes", t, func() {
+		collector, elasticsearch := setupCollector()
+
+		Convey("When orphans are collected in dry-run mode", func() {
+			report, err := collector.Collect(context.Background(), true)
+
+			Convey("Then every orphan is reported and nothing is deleted", func() {
+				So(err, ShouldBeNil)
+				So(report.DryRun, ShouldBeTrue)
+				So(report.Checked, ShouldEqual, 4)
+				So(report.Deleted, ShouldEqual, 0)
+				So(len(report.Orphans), ShouldEqual, 3)
+				So(report.Orphans[0].Index, ShouldEqual, publishedInstanceID+"_geography")
+				So(report.Orphans[0].Reason, ShouldEqual, reasonDimensionNotFound)
+				So(report.Orphans[1].Index, ShouldEqual, detachedInstanceID+"_aggregate")
+				So(report.Orphans[1].Reason, ShouldEqual, reasonInstanceDetached)
+				So(report.Orphans[2].Index, ShouldEqual, deletedInstanceID+"_aggregate")
+				So(report.Orphans[2].Reason, ShouldEqual, reasonInstanceNotFound)
+				So(elasticsearch.Deleted, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When orphans are collected with dry-run mode disabled", func() {
+			report, err := collector.Collect(context.Background(), false)
+
+			Convey("Then every orphan is deleted and indexes belonging to other services are left alone", func() {
+				So(err, ShouldBeNil)
+				So(report.DryRun, ShouldBeFalse)
+				So(report.Deleted, ShouldEqual, 3)
+				So(report.Orphans[0].Deleted, ShouldBeTrue)
+				So(elasticsearch.Deleted, ShouldResemble, []string{
+					publishedInstanceID + "_geography",
+					detachedInstanceID + "_aggregate",
+					deletedInstanceID + "_aggregate",
+				})
+			})
+		})
+	})
+
+	Convey("Given the dataset API is unavailable", t, func() {
+		elasticsearch := &mocks.Elasticsearch{Indexes: []string{publishedInstanceID + "_aggregate"}}
+		collector := NewOrphanCollector(elasticsearch, &mocks.DatasetAPI{InternalServerError: true}, "token")
+
+		Convey("When orphans are collected with dry-run mode disabled", func() {
+			report, err := collector.Collect(context.Background(), false)
+
+			Convey("Then the index is skipped rather than deleted", func() {
+				So(err, ShouldBeNil)
+				So(report.Skipped, ShouldEqual, 1)
+				So(report.Orphans, ShouldBeEmpty)
+				So(elasticsearch.Deleted, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given elasticsearch is unavailable", t, func() {
+		collector := NewOrphanCollector(&mocks.Elasticsearch{InternalServerError: true}, &mocks.DatasetAPI{}, "token")
+
+		Convey("When orphans are collected an error is returned", func() {
+			report, err := collector.Collect(context.Background(), true)
+			So(err, ShouldNotBeNil)
+			So(report, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,22 @@
+// Package reconcile keeps the search indexes held in elasticsearch in step with the
+// instances held by the dataset API
+package reconcile
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/ONSdigital/dp-api-clients-go/dataset"
+)
+
+// SearchIndexes - An interface used to list and remove search indexes
+type SearchIndexes interface {
+	ListSearchIndexes(ctx context.Context) ([]string, error)
+	DeleteSearchIndex(ctx context.Context, instanceID, dimension string) (int, error)
+}
+
+// DatasetAPIClient - An interface used to retrieve instances from the dataset API
+type DatasetAPIClient interface {
+	GetInstance(ctx context.Context, userAuthToken, serviceAuthToken, collectionID, instanceID string) (dataset.Instance, error)
+	GetInstancesInBatches(ctx context.Context, userAuthToken, serviceAuthToken, collectionID string, vars url.Values, batchSize, maxWorkers int) (dataset.Instances, error)
+}
@@ -0,0 +1,23 @@
+#!/usr/bin/env bash
+
+# this script asks the search API to find the hierarchical dimensions of
+# published instances which have no search index
+#
+# by default the missing indexes are only reported - pass '--rebuild' to
+# queue them to be rebuilt
+
+###
+
+dimension_search_api_url=${DIMENSION_SEARCH_API_URL:-localhost:23100}
+# token for accessing the search API (can easily be changed at runtime):
+SERVICE_AUTH_TOKEN=${SERVICE_AUTH_TOKEN:-changeme}
+
+####
+
+dry_run=true
+[[ $1 == --rebuild ]] && dry_run=false
+
+curl -sS --fail-with-body \
+        -H "Authorization: Bearer $SERVICE_AUTH_TOKEN" \
+        -X POST "$dimension_search_api_url/dimension-search/reconcile/missing-indexes?dry_run=$dry_run"
+echo
@@ -0,0 +1,150 @@
+package searchoutputqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	dphttp "github.com/ONSdigital/dp-net/http"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// The output queue backends that can be selected, kafka is used in production and the others
+// allow the service to run without kafka
+const (
+	BackendKafka   = "kafka"
+	BackendMemory  = "memory"
+	BackendFile    = "file"
+	BackendWebhook = "webhook"
+)
+
+// Memory holds queued search outputs in memory so they can be inspected
+type Memory struct {
+	mu       sync.Mutex
+	searches []Search
+}
+
+// CreateMemoryQueue returns an empty in-memory output queue
+func CreateMemoryQueue() *Memory {
+	return &Memory{searches: []Search{}}
+}
+
+// Queue records a search output
+func (search *Memory) Queue(_ context.Context, outputSearch *Search) error {
+	search.mu.Lock()
+	defer search.mu.Unlock()
+
+	search.searches = append(search.searches, *outputSearch)
+	return nil
+}
+
+// Searches returns the search outputs queued so far, oldest first
+func (search *Memory) Searches() []Search {
+	search.mu.Lock()
+	defer search.mu.Unlock()
+
+	searches := make([]Search, len(search.searches))
+	copy(searches, search.searches)
+	return searches
+}
+
+// Clear removes every queued search output
+func (search *Memory) Clear() {
+	search.mu.Lock()
+	defer search.mu.Unlock()
+
+	search.searches = []Search{}
+}
+
+// File writes queued search outputs to a file as newline delimited json
+type File struct {
+	mu   sync.Mutex
+	file *os.File
+	path string
+}
+
+// CreateFileQueue returns an output queue which appends to the file at path, creating it if necessary
+func CreateFileQueue(path string) (*File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{file: file, path: path}, nil
+}
+
+// Queue appends a search output to the file
+func (search *File) Queue(ctx context.Context, outputSearch *Search) error {
+	line, err := json.Marshal(outputSearch)
+	if err != nil {
+		return err
+	}
+
+	search.mu.Lock()
+	defer search.mu.Unlock()
+
+	if _, err = search.file.Write(append(line, '\n')); err != nil {
+		log.Error(ctx, "failed to write search output to file", err, log.Data{"path": search.path})
+		return errs.ErrQueueUnavailable
+	}
+
+	return nil
+}
+
+// Close closes the file
+func (search *File) Close() error {
+	search.mu.Lock()
+	defer search.mu.Unlock()
+
+	return search.file.Close()
+}
+
+// Webhook posts queued search outputs to a URL as json
+type Webhook struct {
+	client dphttp.Clienter
+	url    string
+}
+
+// CreateWebhookQueue returns an output queue which posts each search output to url
+func CreateWebhookQueue(client dphttp.Clienter, url string) *Webhook {
+	return &Webhook{client: client, url: url}
+}
+
+// Queue posts a search output to the webhook, returning ErrQueueUnavailable unless it responds with a 2xx status
+func (search *Webhook) Queue(ctx context.Context, outputSearch *Search) error {
+	logData := log.Data{"url": search.url, "instance_id": outputSearch.InstanceID, "dimension": outputSearch.Dimension}
+
+	body, err := json.Marshal(outputSearch)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, search.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := search.client.Do(ctx, req)
+	if err != nil {
+		log.Error(ctx, "failed to post search output to webhook", err, logData)
+		return errs.ErrQueueUnavailable
+	}
+	defer resp.Body.Close()
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		logData["status"] = resp.StatusCode
+		log.Error(ctx, "webhook rejected search output", errs.ErrQueueUnavailable, logData)
+		return errs.ErrQueueUnavailable
+	}
+
+	return nil
+}
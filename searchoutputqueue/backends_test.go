@@ -0,0 +1,84 @@
+package searchoutputqueue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	dphttp "github.com/ONSdigital/dp-net/http"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMemoryQueue(t *testing.T) {
+	Convey("Given an in-memory output queue", t, func() {
+		outputQueue := CreateMemoryQueue()
+
+		Convey("When search outputs are queued they can be inspected, and cleared", func() {
+			So(outputQueue.Queue(context.Background(), &Search{InstanceID: "123", Dimension: "aggregate"}), ShouldBeNil)
+			So(outputQueue.Queue(context.Background(), &Search{InstanceID: "123", Dimension: "geography"}), ShouldBeNil)
+			So(outputQueue.Searches(), ShouldResemble, []Search{{InstanceID: "123", Dimension: "aggregate"}, {InstanceID: "123", Dimension: "geography"}})
+
+			outputQueue.Clear()
+			So(outputQueue.Searches(), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestFileQueue(t *testing.T) {
+	Convey("Given a file output queue", t, func() {
+		path := filepath.Join(t.TempDir(), "search-outputs.jsonl")
+		outputQueue, err := CreateFileQueue(path)
+		So(err, ShouldBeNil)
+
+		Convey("When search outputs are queued each is written as a line of json", func() {
+			So(outputQueue.Queue(context.Background(), &Search{InstanceID: "123", Dimension: "aggregate"}), ShouldBeNil)
+			So(outputQueue.Queue(context.Background(), &Search{InstanceID: "123", Dimension: "geography"}), ShouldBeNil)
+			So(outputQueue.Close(), ShouldBeNil)
+
+			b, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, `{"dimension":"aggregate","instance_id":"123"}`+"\n"+`{"dimension":"geography","instance_id":"123"}`+"\n")
+		})
+	})
+}
+
+func TestWebhookQueue(t *testing.T) {
+	Convey("Given a webhook which accepts search outputs", t, func() {
+		received := make(chan Search, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var search Search
+			if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&search) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			received <- search
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer server.Close()
+
+		outputQueue := CreateWebhookQueue(dphttp.NewClient(), server.URL)
+
+		Convey("When a search output is queued it is posted to the webhook", func() {
+			So(outputQueue.Queue(context.Background(), &Search{InstanceID: "123", Dimension: "aggregate"}), ShouldBeNil)
+			So(<-received, ShouldResemble, Search{InstanceID: "123", Dimension: "aggregate"})
+		})
+	})
+
+	Convey("Given a webhook which rejects search outputs", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		outputQueue := CreateWebhookQueue(dphttp.NewClient(), server.URL)
+
+		Convey("When a search output is queued the queue is reported as unavailable", func() {
+			So(outputQueue.Queue(context.Background(), &Search{InstanceID: "123", Dimension: "aggregate"}), ShouldEqual, errs.ErrQueueUnavailable)
+		})
+	})
+}
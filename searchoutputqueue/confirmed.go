@@ -0,0 +1,111 @@
+package searchoutputqueue
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/Shopify/sarama/otelsarama"
+	"go.opentelemetry.io/otel"
+)
+
+// SyncProducer - An interface used to send a message and wait for kafka to acknowledge it
+type SyncProducer interface {
+	SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error)
+	Close() error
+}
+
+// brokerChecker - An interface implemented by producers which can check the health of the brokers they send to
+type brokerChecker interface {
+	Checker(ctx context.Context, topic string, state *healthcheck.CheckState) error
+}
+
+// ConfirmedOutput queues search outputs, waiting for kafka to confirm each message has been delivered
+type ConfirmedOutput struct {
+	producer SyncProducer
+	timeout  time.Duration
+	topic    string
+	failed   atomic.Bool
+}
+
+// CreateConfirmedOutputQueue returns an object which queues search outputs on topic, waiting up to
+// timeout for delivery of each to be confirmed
+func CreateConfirmedOutputQueue(producer SyncProducer, topic string, timeout time.Duration) *ConfirmedOutput {
+	return &ConfirmedOutput{
+		producer: producer,
+		timeout:  timeout,
+		topic:    topic,
+	}
+}
+
+// Queue sends a search output to kafka, returning once kafka has confirmed it was delivered. If delivery
+// fails, or is not confirmed in time, ErrQueueDeliveryFailed is returned.
+func (search *ConfirmedOutput) Queue(ctx context.Context, outputSearch *Search) error {
+	logData := log.Data{"instance_id": outputSearch.InstanceID, "dimension": outputSearch.Dimension, "topic": search.topic}
+
+	bytes, err := marshal(outputSearch)
+	if err != nil {
+		return err
+	}
+
+	// propagate the trace of the request, as the dp-kafka producer does
+	message := &sarama.ProducerMessage{Topic: search.topic, Value: sarama.ByteEncoder(bytes)}
+	otel.GetTextMapPropagator().Inject(ctx, otelsarama.NewProducerMessageCarrier(message))
+
+	// buffered so the send can complete after Queue has given up waiting on it
+	delivered := make(chan error, 1)
+	go func() {
+		_, _, sendErr := search.producer.SendMessage(message)
+		delivered <- sendErr
+	}()
+
+	timer := time.NewTimer(search.timeout)
+	defer timer.Stop()
+
+	select {
+	case err = <-delivered:
+		search.failed.Store(err != nil)
+		if err != nil {
+			log.Error(ctx, "failed to deliver search output to kafka", err, logData)
+			return errs.ErrQueueDeliveryFailed
+		}
+		return nil
+	case <-timer.C:
+		search.failed.Store(true)
+		// the message may still be delivered, but the caller should not rely on it
+		log.Error(ctx, "timed out waiting for kafka to confirm delivery of search output", errs.ErrQueueDeliveryFailed, logData)
+		return errs.ErrQueueDeliveryFailed
+	case <-ctx.Done():
+		log.Error(ctx, "request ended before kafka confirmed delivery of search output", ctx.Err(), logData)
+		return errs.ErrQueueDeliveryFailed
+	}
+}
+
+// Checker reports whether the kafka brokers can be reached, when the producer can check them, warning if they
+// can but the last search output was not confirmed as delivered
+func (search *ConfirmedOutput) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+	if checker, ok := search.producer.(brokerChecker); ok {
+		if err := checker.Checker(ctx, search.topic, state); err != nil {
+			return err
+		}
+		if state.Status() != healthcheck.StatusOK {
+			return nil
+		}
+	}
+
+	if search.failed.Load() {
+		return state.Update(healthcheck.StatusWarning, "kafka did not confirm delivery of the last search output to "+search.topic, http.StatusServiceUnavailable)
+	}
+
+	return state.Update(healthcheck.StatusOK, "kafka is confirming delivery to "+search.topic, http.StatusOK)
+}
+
+// Close closes the underlying producer
+func (search *ConfirmedOutput) Close() error {
+	return search.producer.Close()
+}
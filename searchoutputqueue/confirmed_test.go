@@ -0,0 +1,128 @@
+package searchoutputqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/dp-import/events"
+	"github.com/ONSdigital/dp-kafka/v4/interfaces"
+	kafkatest "github.com/ONSdigital/dp-kafka/v4/mock"
+	"github.com/Shopify/sarama"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// syncProducer records the messages sent to it, optionally failing or blocking until released
+type syncProducer struct {
+	err     error
+	release chan struct{}
+	sent    chan *sarama.ProducerMessage
+}
+
+func (p *syncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	if p.release != nil {
+		<-p.release
+	}
+	p.sent <- msg
+	return 0, 0, p.err
+}
+
+func (p *syncProducer) Close() error {
+	return nil
+}
+
+// brokerGenerator returns brokers which are reachable and hold topic when healthy, otherwise cannot be opened
+func brokerGenerator(healthy bool, topic string) interfaces.BrokerGenerator {
+	return func(addr string) interfaces.SaramaBroker {
+		return &kafkatest.SaramaBrokerMock{
+			AddrFunc:      func() string { return addr },
+			CloseFunc:     func() error { return nil },
+			ConnectedFunc: func() (bool, error) { return healthy, nil },
+			OpenFunc:      func(*sarama.Config) error { return errors.New("connection refused") },
+			GetMetadataFunc: func(*sarama.MetadataRequest) (*sarama.MetadataResponse, error) {
+				if !healthy {
+					return nil, errors.New("connection refused")
+				}
+				return &sarama.MetadataResponse{Topics: []*sarama.TopicMetadata{{Name: topic}}}, nil
+			},
+		}
+	}
+}
+
+func TestConfirmedOutputQueue(t *testing.T) {
+	search := &Search{InstanceID: "12345678", Dimension: "aggregate"}
+
+	Convey("Given kafka confirms delivery of the message", t, func() {
+		producer := &syncProducer{sent: make(chan *sarama.ProducerMessage, 1)}
+		outputQueue := CreateConfirmedOutputQueue(producer, "hierarchy-built", time.Second)
+
+		Convey("When a search output is queued, it is sent to the topic and no error is returned", func() {
+			So(outputQueue.Queue(context.Background(), search), ShouldBeNil)
+
+			message := <-producer.sent
+			So(message.Topic, ShouldEqual, "hierarchy-built")
+
+			value, err := message.Value.Encode()
+			So(err, ShouldBeNil)
+
+			var searchMessage events.HierarchyBuilt
+			So(events.HierarchyBuiltSchema.Unmarshal(value, &searchMessage), ShouldBeNil)
+			So(searchMessage.InstanceID, ShouldEqual, search.InstanceID)
+			So(searchMessage.DimensionName, ShouldEqual, search.Dimension)
+
+			state := healthcheck.NewCheckState("Kafka Producer")
+			So(outputQueue.Checker(context.Background(), state), ShouldBeNil)
+			So(state.Status(), ShouldEqual, healthcheck.StatusOK)
+		})
+	})
+
+	Convey("Given kafka fails to deliver the message", t, func() {
+		producer := &syncProducer{err: errors.New("leader not available"), sent: make(chan *sarama.ProducerMessage, 1)}
+		outputQueue := CreateConfirmedOutputQueue(producer, "hierarchy-built", time.Second)
+
+		Convey("When a search output is queued, a delivery failure is returned", func() {
+			So(outputQueue.Queue(context.Background(), search), ShouldEqual, errs.ErrQueueDeliveryFailed)
+
+			Convey("And the health check warns", func() {
+				state := healthcheck.NewCheckState("Kafka Producer")
+				So(outputQueue.Checker(context.Background(), state), ShouldBeNil)
+				So(state.Status(), ShouldEqual, healthcheck.StatusWarning)
+			})
+		})
+	})
+
+	Convey("Given kafka does not confirm delivery of the message in time", t, func() {
+		producer := &syncProducer{release: make(chan struct{}), sent: make(chan *sarama.ProducerMessage, 1)}
+		defer close(producer.release)
+		outputQueue := CreateConfirmedOutputQueue(producer, "hierarchy-built", 10*time.Millisecond)
+
+		Convey("When a search output is queued, a delivery failure is returned once the timeout has passed", func() {
+			So(outputQueue.Queue(context.Background(), search), ShouldEqual, errs.ErrQueueDeliveryFailed)
+		})
+	})
+
+	Convey("Given the kafka brokers cannot be reached", t, func() {
+		producer := newSyncProducer([]string{"localhost:9092", "localhost:9093"}, sarama.NewConfig(), 2, brokerGenerator(false, "hierarchy-built"))
+		outputQueue := CreateConfirmedOutputQueue(producer, "hierarchy-built", time.Second)
+
+		Convey("When the health check runs before any search output is queued, it is critical", func() {
+			state := healthcheck.NewCheckState("Kafka Producer")
+			So(outputQueue.Checker(context.Background(), state), ShouldBeNil)
+			So(state.Status(), ShouldEqual, healthcheck.StatusCritical)
+		})
+	})
+
+	Convey("Given the kafka brokers can be reached and hold the topic", t, func() {
+		producer := newSyncProducer([]string{"localhost:9092", "localhost:9093"}, sarama.NewConfig(), 2, brokerGenerator(true, "hierarchy-built"))
+		outputQueue := CreateConfirmedOutputQueue(producer, "hierarchy-built", time.Second)
+
+		Convey("When the health check runs, it is OK", func() {
+			state := healthcheck.NewCheckState("Kafka Producer")
+			So(outputQueue.Checker(context.Background(), state), ShouldBeNil)
+			So(state.Status(), ShouldEqual, healthcheck.StatusOK)
+		})
+	})
+}
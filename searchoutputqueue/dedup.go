@@ -0,0 +1,96 @@
+package searchoutputqueue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// Queuer - An interface used to queue search outputs
+type Queuer interface {
+	Queue(ctx context.Context, outputSearch *Search) error
+}
+
+// Deduplicator sits in front of an output queue, coalescing a search output with an identical one
+// queued within the window rather than queueing it again
+type Deduplicator struct {
+	mu     sync.Mutex
+	queue  Queuer
+	queued map[Search]time.Time
+	now    func() time.Time
+	window time.Duration
+}
+
+// NewDeduplicator returns a Deduplicator which queues search outputs on queue
+func NewDeduplicator(queue Queuer, window time.Duration) *Deduplicator {
+	return &Deduplicator{
+		queue:  queue,
+		queued: make(map[Search]time.Time),
+		now:    time.Now,
+		window: window,
+	}
+}
+
+// Queue queues a search output unless an identical one was queued within the window
+func (dedup *Deduplicator) Queue(ctx context.Context, outputSearch *Search) error {
+	_, err := dedup.QueueOnce(ctx, outputSearch, false)
+	return err
+}
+
+// QueueOnce queues a search output, returning true without queueing it if an identical one was
+// queued within the window. If force is set the search output is always queued.
+func (dedup *Deduplicator) QueueOnce(ctx context.Context, outputSearch *Search, force bool) (coalesced bool, err error) {
+	key := *outputSearch
+	now := dedup.now()
+
+	dedup.mu.Lock()
+	dedup.expire(now)
+	previous, ok := dedup.queued[key]
+	if ok && !force {
+		dedup.mu.Unlock()
+		log.Info(ctx, "coalesced search output with one recently queued", log.Data{"instance_id": key.InstanceID, "dimension": key.Dimension, "queued_at": previous})
+		return true, nil
+	}
+	// recorded before queueing so that identical requests arriving meanwhile are coalesced
+	dedup.queued[key] = now
+	dedup.mu.Unlock()
+
+	if err = dedup.queue.Queue(ctx, outputSearch); err != nil {
+		dedup.mu.Lock()
+		if queuedAt, ok := dedup.queued[key]; ok && queuedAt.Equal(now) {
+			if previous.IsZero() {
+				delete(dedup.queued, key)
+			} else {
+				dedup.queued[key] = previous
+			}
+		}
+		dedup.mu.Unlock()
+		return false, err
+	}
+
+	return false, nil
+}
+
+// Unwrap returns the output queue search outputs are queued on
+func (dedup *Deduplicator) Unwrap() Queuer {
+	return dedup.queue
+}
+
+// Close closes the output queue, if it can be closed
+func (dedup *Deduplicator) Close() error {
+	if closer, ok := dedup.queue.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// expire forgets search outputs queued before the window
+func (dedup *Deduplicator) expire(now time.Time) {
+	for search, queuedAt := range dedup.queued {
+		if now.Sub(queuedAt) >= dedup.window {
+			delete(dedup.queued, search)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package searchoutputqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// unavailableQueue fails to queue any search output
+type unavailableQueue struct{}
+
+func (unavailableQueue) Queue(context.Context, *Search) error {
+	return errs.ErrQueueUnavailable
+}
+
+func TestDeduplicator(t *testing.T) {
+	ctx := context.Background()
+	search := &Search{InstanceID: "123", Dimension: "aggregate"}
+
+	Convey("Given a search output has been queued", t, func() {
+		queue := CreateMemoryQueue()
+		dedup := NewDeduplicator(queue, time.Minute)
+		now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		dedup.now = func() time.Time { return now }
+
+		coalesced, err := dedup.QueueOnce(ctx, search, false)
+		So(err, ShouldBeNil)
+		So(coalesced, ShouldBeFalse)
+
+		Convey("When an identical search output is queued within the window it is coalesced", func() {
+			now = now.Add(59 * time.Second)
+			coalesced, err = dedup.QueueOnce(ctx, search, false)
+			So(err, ShouldBeNil)
+			So(coalesced, ShouldBeTrue)
+			So(queue.Searches(), ShouldHaveLength, 1)
+		})
+
+		Convey("When an identical search output is forced it is queued again", func() {
+			coalesced, err = dedup.QueueOnce(ctx, search, true)
+			So(err, ShouldBeNil)
+			So(coalesced, ShouldBeFalse)
+			So(queue.Searches(), ShouldHaveLength, 2)
+		})
+
+		Convey("When a search output for another dimension is queued it is not coalesced", func() {
+			So(dedup.Queue(ctx, &Search{InstanceID: "123", Dimension: "geography"}), ShouldBeNil)
+			So(queue.Searches(), ShouldHaveLength, 2)
+		})
+
+		Convey("When an identical search output is queued after the window it is queued again", func() {
+			now = now.Add(time.Minute)
+			coalesced, err = dedup.QueueOnce(ctx, search, false)
+			So(err, ShouldBeNil)
+			So(coalesced, ShouldBeFalse)
+			So(queue.Searches(), ShouldHaveLength, 2)
+		})
+	})
+
+	Convey("Given a search output could not be queued", t, func() {
+		dedup := NewDeduplicator(unavailableQueue{}, time.Minute)
+		So(dedup.Queue(ctx, search), ShouldEqual, errs.ErrQueueUnavailable)
+
+		Convey("When it is requested again it is not coalesced", func() {
+			dedup.queue = CreateMemoryQueue()
+			coalesced, err := dedup.QueueOnce(ctx, search, false)
+			So(err, ShouldBeNil)
+			So(coalesced, ShouldBeFalse)
+		})
+	})
+}
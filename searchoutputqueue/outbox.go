@@ -0,0 +1,347 @@
+package searchoutputqueue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+const (
+	// maxRelayBackoff caps the time between attempts to forward a search output to kafka
+	maxRelayBackoff = time.Minute
+
+	// compactAfter is the number of delivered search outputs recorded before the outbox file is rewritten
+	compactAfter = 1000
+)
+
+// Forwarder - An interface used to deliver search outputs from the outbox, returning once delivery is confirmed
+type Forwarder interface {
+	Queue(ctx context.Context, outputSearch *Search) error
+}
+
+// outboxRecord represents a line in the outbox file, either a search output waiting to be delivered
+// or an acknowledgement that the search output with the same ID has been delivered
+type outboxRecord struct {
+	ID         uint64 `json:"id"`
+	Delivered  bool   `json:"delivered,omitempty"`
+	Dimension  string `json:"dimension,omitempty"`
+	InstanceID string `json:"instance_id,omitempty"`
+}
+
+// Outbox queues search outputs by appending them to a local file, from which a relay forwards them to
+// kafka. Search outputs waiting to be delivered survive a restart.
+type Outbox struct {
+	mu            sync.Mutex
+	file          *os.File
+	forwarder     Forwarder
+	lastErr       error
+	nextID        uint64
+	path          string
+	pending       []outboxRecord
+	delivered     int
+	ready         chan struct{}
+	retryInterval time.Duration
+}
+
+// NewOutbox opens, or creates, the outbox file at path, loading any search outputs still waiting to be
+// delivered. The relay retries failed deliveries, starting after retryInterval and backing off.
+func NewOutbox(path string, forwarder Forwarder, retryInterval time.Duration) (*Outbox, error) {
+	outbox := &Outbox{
+		forwarder:     forwarder,
+		path:          path,
+		ready:         make(chan struct{}, 1),
+		retryInterval: retryInterval,
+	}
+
+	if err := outbox.load(); err != nil {
+		return nil, err
+	}
+
+	// rewrite the file so that only search outputs waiting to be delivered remain
+	if err := outbox.compact(); err != nil {
+		return nil, err
+	}
+
+	if len(outbox.pending) > 0 {
+		outbox.ready <- struct{}{}
+	}
+
+	return outbox, nil
+}
+
+// Queue appends a search output to the outbox, returning once it has been written to disk
+func (outbox *Outbox) Queue(ctx context.Context, outputSearch *Search) error {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	record := outboxRecord{ID: outbox.nextID, Dimension: outputSearch.Dimension, InstanceID: outputSearch.InstanceID}
+
+	if err := outbox.append(record); err != nil {
+		log.Error(ctx, "failed to write search output to outbox", err, log.Data{"path": outbox.path, "instance_id": outputSearch.InstanceID, "dimension": outputSearch.Dimension})
+		return errs.ErrQueueUnavailable
+	}
+
+	outbox.nextID++
+	outbox.pending = append(outbox.pending, record)
+
+	select {
+	case outbox.ready <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Run forwards search outputs from the outbox, oldest first, until ctx is done. Each is removed
+// from the outbox once the forwarder confirms delivery.
+func (outbox *Outbox) Run(ctx context.Context) {
+	backoff := outbox.retryInterval
+
+	for {
+		record, ok := outbox.next()
+		if !ok {
+			select {
+			case <-outbox.ready:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		logData := log.Data{"id": record.ID, "instance_id": record.InstanceID, "dimension": record.Dimension}
+
+		err := outbox.forwarder.Queue(ctx, &Search{Dimension: record.Dimension, InstanceID: record.InstanceID})
+		outbox.setLastErr(err)
+		if err != nil {
+			logData["retry_in"] = backoff.String()
+			log.Error(ctx, "failed to forward search output from outbox, will retry", err, logData)
+
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+
+			backoff *= 2
+			if backoff > maxRelayBackoff {
+				backoff = maxRelayBackoff
+			}
+			continue
+		}
+		backoff = outbox.retryInterval
+
+		if err = outbox.markDelivered(record.ID); err != nil {
+			// the search output will be forwarded again after a restart
+			log.Error(ctx, "failed to remove delivered search output from outbox", err, logData)
+		}
+	}
+}
+
+// Pending returns the number of search outputs waiting to be delivered
+func (outbox *Outbox) Pending() int {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	return len(outbox.pending)
+}
+
+// Checker reports the number of search outputs waiting to be delivered, warning if the last attempt to deliver one failed
+func (outbox *Outbox) Checker(_ context.Context, state *healthcheck.CheckState) error {
+	outbox.mu.Lock()
+	pending, lastErr := len(outbox.pending), outbox.lastErr
+	outbox.mu.Unlock()
+
+	if lastErr != nil {
+		return state.Update(healthcheck.StatusWarning, fmt.Sprintf("failing to forward search outputs to kafka, outbox pending: %d", pending), http.StatusServiceUnavailable)
+	}
+
+	return state.Update(healthcheck.StatusOK, fmt.Sprintf("outbox pending: %d", pending), http.StatusOK)
+}
+
+// Close closes the outbox file, and the forwarder if it can be closed
+func (outbox *Outbox) Close() error {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	if closer, ok := outbox.forwarder.(interface{ Close() error }); ok {
+		if err := closer.Close(); err != nil {
+			return err
+		}
+	}
+
+	if outbox.file == nil {
+		return nil
+	}
+	err := outbox.file.Close()
+	outbox.file = nil
+
+	return err
+}
+
+// next returns the oldest search output waiting to be delivered
+func (outbox *Outbox) next() (outboxRecord, bool) {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	if len(outbox.pending) == 0 {
+		return outboxRecord{}, false
+	}
+	return outbox.pending[0], true
+}
+
+func (outbox *Outbox) setLastErr(err error) {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	outbox.lastErr = err
+}
+
+// markDelivered records that a search output has been delivered, rewriting the file once enough have been
+func (outbox *Outbox) markDelivered(id uint64) error {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+
+	for i := range outbox.pending {
+		if outbox.pending[i].ID == id {
+			outbox.pending = append(outbox.pending[:i], outbox.pending[i+1:]...)
+			break
+		}
+	}
+
+	if err := outbox.append(outboxRecord{ID: id, Delivered: true}); err != nil {
+		return err
+	}
+	outbox.delivered++
+
+	if len(outbox.pending) == 0 || outbox.delivered >= compactAfter {
+		return outbox.compact()
+	}
+
+	return nil
+}
+
+// append writes a record to the end of the outbox file and flushes it to disk
+func (outbox *Outbox) append(record outboxRecord) error {
+	if outbox.file == nil {
+		return os.ErrClosed
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err = outbox.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return outbox.file.Sync()
+}
+
+// load reads the outbox file, if there is one, keeping the search outputs which have not been delivered
+func (outbox *Outbox) load() error {
+	file, err := os.Open(outbox.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	records := make(map[uint64]outboxRecord)
+	var order []uint64
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record outboxRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// a partially written last line is left behind if the service stopped mid-write
+			log.Error(context.Background(), "skipping unreadable line in outbox", err, log.Data{"path": outbox.path})
+			continue
+		}
+
+		if record.ID >= outbox.nextID {
+			outbox.nextID = record.ID + 1
+		}
+
+		if record.Delivered {
+			delete(records, record.ID)
+			continue
+		}
+		records[record.ID] = record
+		order = append(order, record.ID)
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range order {
+		if record, ok := records[id]; ok {
+			outbox.pending = append(outbox.pending, record)
+		}
+	}
+
+	return nil
+}
+
+// compact replaces the outbox file with one holding only the search outputs waiting to be delivered.
+// The new file is opened for appending before it replaces the old one, so if compacting fails the outbox
+// keeps appending to the old file.
+func (outbox *Outbox) compact() error {
+	tmpPath := outbox.path + ".tmp"
+
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if err = writeRecords(tmp, outbox.pending); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err = os.Rename(tmpPath, outbox.path); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// the handle follows the file it was opened on through the rename
+	if outbox.file != nil {
+		outbox.file.Close()
+	}
+	outbox.file = tmp
+	outbox.delivered = 0
+
+	return nil
+}
+
+// writeRecords writes records to file, one per line, and flushes them to disk
+func writeRecords(file *os.File, records []outboxRecord) error {
+	writer := bufio.NewWriter(file)
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err = writer.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
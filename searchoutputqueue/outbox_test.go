@@ -0,0 +1,143 @@
+package searchoutputqueue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// forwarder records the search outputs delivered to it, failing the first failures attempts
+type forwarder struct {
+	mu        sync.Mutex
+	failures  int
+	attempts  int
+	delivered []Search
+}
+
+func (f *forwarder) Queue(_ context.Context, outputSearch *Search) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.attempts++
+	if f.attempts <= f.failures {
+		return errors.New("kafka unavailable")
+	}
+	f.delivered = append(f.delivered, *outputSearch)
+	return nil
+}
+
+func (f *forwarder) deliveredCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.delivered)
+}
+
+func waitForDelivery(f *forwarder, count int) bool {
+	for i := 0; i < 200; i++ {
+		if f.deliveredCount() == count {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestOutbox(t *testing.T) {
+	aggregate := &Search{InstanceID: "12345678", Dimension: "aggregate"}
+	geography := &Search{InstanceID: "12345678", Dimension: "geography"}
+
+	Convey("Given an outbox whose relay is not running", t, func() {
+		path := filepath.Join(t.TempDir(), "outbox.jsonl")
+		outbox, err := NewOutbox(path, &forwarder{}, time.Millisecond)
+		So(err, ShouldBeNil)
+
+		Convey("When search outputs are queued they are written to the outbox file", func() {
+			So(outbox.Queue(context.Background(), aggregate), ShouldBeNil)
+			So(outbox.Queue(context.Background(), geography), ShouldBeNil)
+			So(outbox.Pending(), ShouldEqual, 2)
+
+			Convey("Then they are still waiting to be delivered after a restart", func() {
+				So(outbox.Close(), ShouldBeNil)
+
+				f := &forwarder{}
+				restarted, err := NewOutbox(path, f, time.Millisecond)
+				So(err, ShouldBeNil)
+				So(restarted.Pending(), ShouldEqual, 2)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				go restarted.Run(ctx)
+
+				So(waitForDelivery(f, 2), ShouldBeTrue)
+				So(f.delivered, ShouldResemble, []Search{*aggregate, *geography})
+			})
+		})
+
+		Convey("When the service stopped part way through writing a search output the rest of the outbox is still loaded", func() {
+			So(outbox.Queue(context.Background(), aggregate), ShouldBeNil)
+			So(outbox.Close(), ShouldBeNil)
+
+			file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+			So(err, ShouldBeNil)
+			_, err = file.WriteString(`{"id":1,"dimen`)
+			So(err, ShouldBeNil)
+			So(file.Close(), ShouldBeNil)
+
+			restarted, err := NewOutbox(path, &forwarder{}, time.Millisecond)
+			So(err, ShouldBeNil)
+			So(restarted.Pending(), ShouldEqual, 1)
+		})
+
+		Convey("When the outbox file cannot be compacted, search outputs are still written to the old file", func() {
+			So(outbox.Queue(context.Background(), aggregate), ShouldBeNil)
+
+			// a directory in the way of the temporary file fails the compaction
+			So(os.Mkdir(path+".tmp", 0o700), ShouldBeNil)
+			So(outbox.compact(), ShouldNotBeNil)
+			So(outbox.Queue(context.Background(), geography), ShouldBeNil)
+			So(outbox.Close(), ShouldBeNil)
+
+			So(os.Remove(path+".tmp"), ShouldBeNil)
+			restarted, err := NewOutbox(path, &forwarder{}, time.Millisecond)
+			So(err, ShouldBeNil)
+			So(restarted.Pending(), ShouldEqual, 2)
+		})
+	})
+
+	Convey("Given an outbox whose relay fails to deliver the first attempts", t, func() {
+		path := filepath.Join(t.TempDir(), "outbox.jsonl")
+		f := &forwarder{failures: 2}
+		outbox, err := NewOutbox(path, f, time.Millisecond)
+		So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go outbox.Run(ctx)
+
+		Convey("When a search output is queued it is retried until delivered, then removed from the outbox", func() {
+			So(outbox.Queue(context.Background(), aggregate), ShouldBeNil)
+
+			So(waitForDelivery(f, 1), ShouldBeTrue)
+			So(f.attempts, ShouldEqual, 3)
+
+			for i := 0; i < 200 && outbox.Pending() > 0; i++ {
+				time.Sleep(5 * time.Millisecond)
+			}
+			So(outbox.Pending(), ShouldEqual, 0)
+
+			cancel()
+			So(outbox.Close(), ShouldBeNil)
+
+			b, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(strings.TrimSpace(string(b)), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,53 @@
+package searchoutputqueue
+
+import "context"
+
+// Priorities a search output can be queued with
+const (
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+// PriorityRouter queues high priority search outputs on a separate queue, so that urgent rebuilds
+// are not held behind a backlog of bulk rebuilds
+type PriorityRouter struct {
+	normal Queuer
+	high   Queuer
+}
+
+// NewPriorityRouter returns a PriorityRouter which queues high priority search outputs on high,
+// and all others on normal
+func NewPriorityRouter(normal, high Queuer) *PriorityRouter {
+	return &PriorityRouter{normal: normal, high: high}
+}
+
+// Queue queues a search output according to its priority
+func (router *PriorityRouter) Queue(ctx context.Context, outputSearch *Search) error {
+	if outputSearch.Priority == PriorityHigh {
+		return router.high.Queue(ctx, outputSearch)
+	}
+	return router.normal.Queue(ctx, outputSearch)
+}
+
+// Unwrap returns the queue normal priority search outputs are queued on
+func (router *PriorityRouter) Unwrap() Queuer {
+	return router.normal
+}
+
+// QueuesHighPriority reports that high priority search outputs are queued separately
+func (router *PriorityRouter) QueuesHighPriority() bool {
+	return router.high != nil
+}
+
+// Close closes both queues, if they can be closed
+func (router *PriorityRouter) Close() error {
+	var firstErr error
+	for _, queue := range []Queuer{router.normal, router.high} {
+		if closer, ok := queue.(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
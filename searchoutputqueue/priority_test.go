@@ -0,0 +1,27 @@
+package searchoutputqueue
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPriorityRouter(t *testing.T) {
+	Convey("Given a priority router in front of a normal and a high priority queue", t, func() {
+		normal, high := CreateMemoryQueue(), CreateMemoryQueue()
+		router := NewPriorityRouter(normal, high)
+
+		Convey("When a high priority search output is queued it is queued on the high priority queue", func() {
+			So(router.Queue(context.Background(), &Search{InstanceID: "123", Dimension: "aggregate", Priority: PriorityHigh}), ShouldBeNil)
+			So(high.Searches(), ShouldHaveLength, 1)
+			So(normal.Searches(), ShouldBeEmpty)
+		})
+
+		Convey("When a search output without a priority is queued it is queued on the normal queue", func() {
+			So(router.Queue(context.Background(), &Search{InstanceID: "123", Dimension: "aggregate"}), ShouldBeNil)
+			So(normal.Searches(), ShouldHaveLength, 1)
+			So(high.Searches(), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,81 @@
+package searchoutputqueue
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	kafka "github.com/ONSdigital/dp-kafka/v4"
+	"github.com/ONSdigital/dp-kafka/v4/interfaces"
+	"github.com/Shopify/sarama"
+)
+
+// lazySyncProducer connects to kafka on first use, so the service can start while kafka is unavailable.
+// If connecting fails it is retried on the next send. The dp-kafka producer does not report when each
+// message is delivered, so the sarama producer it wraps is used directly.
+type lazySyncProducer struct {
+	mu                sync.Mutex
+	brokerAddrs       []string
+	brokers           []interfaces.SaramaBroker
+	config            *sarama.Config
+	minBrokersHealthy int
+	producer          sarama.SyncProducer
+}
+
+// NewSyncProducer returns a producer which waits for kafka to acknowledge each message. The config must
+// return successes and errors. The producer is healthy while at least minBrokersHealthy brokers are.
+func NewSyncProducer(brokerAddrs []string, config *sarama.Config, minBrokersHealthy int) SyncProducer {
+	return newSyncProducer(brokerAddrs, config, minBrokersHealthy, kafka.SaramaNewBroker)
+}
+
+func newSyncProducer(brokerAddrs []string, config *sarama.Config, minBrokersHealthy int, brokerGenerator interfaces.BrokerGenerator) *lazySyncProducer {
+	brokers := make([]interfaces.SaramaBroker, 0, len(brokerAddrs))
+	for _, addr := range brokerAddrs {
+		brokers = append(brokers, brokerGenerator(addr))
+	}
+
+	return &lazySyncProducer{brokerAddrs: brokerAddrs, brokers: brokers, config: config, minBrokersHealthy: minBrokersHealthy}
+}
+
+// SendMessage sends a message, connecting to kafka first if not already connected
+func (p *lazySyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	producer, err := p.connect()
+	if err != nil {
+		return 0, 0, err
+	}
+	return producer.SendMessage(msg)
+}
+
+// Checker reports whether enough brokers can be reached and hold topic, as the dp-kafka producer checker does
+func (p *lazySyncProducer) Checker(ctx context.Context, topic string, state *healthcheck.CheckState) error {
+	info := kafka.Healthcheck(ctx, p.brokers, topic, p.config)
+	return info.UpdateStatus(state, p.minBrokersHealthy, kafka.MsgHealthyProducer)
+}
+
+// Close closes the connection to kafka, if one was made
+func (p *lazySyncProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.producer == nil {
+		return nil
+	}
+	return p.producer.Close()
+}
+
+func (p *lazySyncProducer) connect() (sarama.SyncProducer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.producer != nil {
+		return p.producer, nil
+	}
+
+	producer, err := sarama.NewSyncProducer(p.brokerAddrs, p.config)
+	if err != nil {
+		return nil, err
+	}
+	p.producer = producer
+
+	return producer, nil
+}
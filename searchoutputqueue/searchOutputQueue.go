@@ -2,40 +2,92 @@ package searchoutputqueue
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
 
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	"github.com/ONSdigital/dp-import/events"
 	kafka "github.com/ONSdigital/dp-kafka/v4"
+	"github.com/ONSdigital/log.go/v2/log"
 )
 
 // Output is an object containing the search output queue channel
 type Output struct {
 	searchOutputQueue chan kafka.BytesMessage
+	enqueueTimeout    time.Duration
+	depth             atomic.Int64
+	timedOut          atomic.Bool
 }
 
 // Search is an object containing the unique values to create a search index
 type Search struct {
-	Dimension  string
-	InstanceID string
+	Dimension  string `json:"dimension"`
+	InstanceID string `json:"instance_id"`
+	Priority   string `json:"priority,omitempty"`
 }
 
-// CreateOutputQueue returns an object containing a channel for queueing filter outputs
-func CreateOutputQueue(queue chan kafka.BytesMessage) Output {
-	return Output{searchOutputQueue: queue}
+// CreateOutputQueue returns an object containing a channel for queueing filter outputs. Queueing
+// gives up once enqueueTimeout has passed without the producer accepting the message.
+func CreateOutputQueue(queue chan kafka.BytesMessage, enqueueTimeout time.Duration) *Output {
+	return &Output{searchOutputQueue: queue, enqueueTimeout: enqueueTimeout}
 }
 
-// Queue represents a mechanism to add messages to the filter jobs queue
+// Queue represents a mechanism to add messages to the filter jobs queue. If the producer does not
+// accept the message before the enqueue timeout, or ctx is done, ErrQueueUnavailable is returned.
 func (search *Output) Queue(ctx context.Context, outputSearch *Search) error {
-	message := &events.HierarchyBuilt{
-		DimensionName: outputSearch.Dimension,
-		InstanceID:    outputSearch.InstanceID,
-	}
-
-	bytes, err := events.HierarchyBuiltSchema.Marshal(message)
+	bytes, err := marshal(outputSearch)
 	if err != nil {
 		return err
 	}
 
-	search.searchOutputQueue <- kafka.BytesMessage{Value: bytes, Context: ctx}
+	search.depth.Add(1)
+	defer search.depth.Add(-1)
+
+	timer := time.NewTimer(search.enqueueTimeout)
+	defer timer.Stop()
+
+	logData := log.Data{"instance_id": outputSearch.InstanceID, "dimension": outputSearch.Dimension, "timeout": search.enqueueTimeout.String()}
+
+	select {
+	case search.searchOutputQueue <- kafka.BytesMessage{Value: bytes, Context: ctx}:
+		search.timedOut.Store(false)
+		return nil
+	case <-timer.C:
+		search.timedOut.Store(true)
+		logData["depth"] = search.Depth()
+		log.Error(ctx, "timed out waiting for kafka producer to accept search output", errs.ErrQueueUnavailable, logData)
+		return errs.ErrQueueUnavailable
+	case <-ctx.Done():
+		log.Error(ctx, "request ended before kafka producer accepted search output", ctx.Err(), logData)
+		return errs.ErrQueueUnavailable
+	}
+}
+
+// Depth returns the number of search outputs waiting for the producer to accept them
+func (search *Output) Depth() int {
+	return int(search.depth.Load())
+}
+
+// Checker reports the queue depth, warning if the last search output could not be queued in time
+func (search *Output) Checker(_ context.Context, state *healthcheck.CheckState) error {
+	depth := search.Depth()
+
+	if search.timedOut.Load() {
+		return state.Update(healthcheck.StatusWarning, fmt.Sprintf("kafka producer is not accepting search outputs, queue depth: %d", depth), http.StatusServiceUnavailable)
+	}
+
+	return state.Update(healthcheck.StatusOK, fmt.Sprintf("queue depth: %d", depth), http.StatusOK)
+}
+
+// marshal encodes a search output as a hierarchy built event
+func marshal(outputSearch *Search) ([]byte, error) {
+	message := &events.HierarchyBuilt{
+		DimensionName: outputSearch.Dimension,
+		InstanceID:    outputSearch.InstanceID,
+	}
 
-	return nil
+	return events.HierarchyBuiltSchema.Marshal(message)
 }
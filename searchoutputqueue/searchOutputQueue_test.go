@@ -3,7 +3,10 @@ package searchoutputqueue
 import (
 	"context"
 	"testing"
+	"time"
 
+	errs "github.com/ONSdigital/dp-dimension-search-api/apierrors"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	"github.com/ONSdigital/dp-import/events"
 	kafka "github.com/ONSdigital/dp-kafka/v4"
 	. "github.com/smartystreets/goconvey/convey"
@@ -12,7 +15,7 @@ import (
 func TestFilterOuputQueue(t *testing.T) {
 	Convey("When a search output is created, a message is sent to kafka", t, func() {
 		searchOutputQueue := make(chan kafka.BytesMessage, 1)
-		outputQueue := CreateOutputQueue(searchOutputQueue)
+		outputQueue := CreateOutputQueue(searchOutputQueue, time.Second)
 		search := Search{InstanceID: "12345678", Dimension: "aggregate"}
 		err := outputQueue.Queue(context.Background(), &search)
 		So(err, ShouldBeNil)
@@ -28,3 +31,59 @@ func TestFilterOuputQueue(t *testing.T) {
 		So(searchMessage.DimensionName, ShouldEqual, search.Dimension)
 	})
 }
+
+func TestStalledOutputQueue(t *testing.T) {
+	search := Search{InstanceID: "12345678", Dimension: "aggregate"}
+
+	Convey("Given the kafka producer is not accepting messages", t, func() {
+		outputQueue := CreateOutputQueue(make(chan kafka.BytesMessage), 20*time.Millisecond)
+
+		Convey("When a search output is queued, the queue is reported as unavailable once the timeout has passed", func() {
+			So(outputQueue.Queue(context.Background(), &search), ShouldEqual, errs.ErrQueueUnavailable)
+			So(outputQueue.Depth(), ShouldEqual, 0)
+
+			state := healthcheck.NewCheckState("Search Output Queue")
+			So(outputQueue.Checker(context.Background(), state), ShouldBeNil)
+			So(state.Status(), ShouldEqual, healthcheck.StatusWarning)
+		})
+
+		Convey("When the request is cancelled while queueing, the queue is reported as unavailable straight away", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			outputQueue.enqueueTimeout = time.Minute
+			So(outputQueue.Queue(ctx, &search), ShouldEqual, errs.ErrQueueUnavailable)
+		})
+
+		Convey("When search outputs are waiting to be queued, they are counted in the queue depth", func() {
+			outputQueue.enqueueTimeout = time.Minute
+			ctx, cancel := context.WithCancel(context.Background())
+
+			done := make(chan error, 2)
+			go func() { done <- outputQueue.Queue(ctx, &search) }()
+			go func() { done <- outputQueue.Queue(ctx, &search) }()
+
+			So(waitForDepth(outputQueue, 2), ShouldBeTrue)
+
+			state := healthcheck.NewCheckState("Search Output Queue")
+			So(outputQueue.Checker(context.Background(), state), ShouldBeNil)
+			So(state.Status(), ShouldEqual, healthcheck.StatusOK)
+			So(state.Message(), ShouldEqual, "queue depth: 2")
+
+			cancel()
+			<-done
+			<-done
+			So(outputQueue.Depth(), ShouldEqual, 0)
+		})
+	})
+}
+
+func waitForDepth(outputQueue *Output, depth int) bool {
+	for i := 0; i < 100; i++ {
+		if outputQueue.Depth() == depth {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
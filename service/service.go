@@ -2,6 +2,7 @@ package service
 
 import (
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"os/signal"
@@ -14,6 +15,7 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/ONSdigital/dp-dimension-search-api/api"
+	"github.com/ONSdigital/dp-dimension-search-api/reconcile"
 	"github.com/ONSdigital/dp-dimension-search-api/searchoutputqueue"
 
 	"github.com/ONSdigital/log.go/v2/log"
@@ -30,10 +32,19 @@ type Service struct {
 	EnvMax                     int
 	HealthCheck                *healthcheck.HealthCheck
 	HealthCheckCriticalTimeout time.Duration
+	HierarchyAPIClient         api.HierarchyAPIClient
+	InstallIndexTemplate       bool
+	InstanceRemovedConsumer    *kafka.ConsumerGroup
 	MaxRetries                 int
-	OutputQueue                searchoutputqueue.Output
+	MissingIndexReconciler     *reconcile.MissingIndexReconciler
+	OrphanCollector            *reconcile.OrphanCollector
+	Outbox                     *searchoutputqueue.Outbox
+	OrphanedIndexGCDryRun      bool
+	OrphanedIndexGCInterval    time.Duration
+	OutputQueue                api.OutputQueue
 	SearchAPIURL               string
 	HierarchyBuiltProducer     *kafka.Producer
+	PriorityProducer           *kafka.Producer
 	OTServiceName              string
 	ServiceAuthToken           string
 	Shutdown                   time.Duration
@@ -62,10 +73,13 @@ func (svc *Service) Start(ctx context.Context) {
 		svc.BindAddr,
 		svc.AuthAPIURL,
 		apiErrors,
-		&svc.OutputQueue,
+		svc.OutputQueue,
 		svc.DatasetAPIClient,
+		svc.HierarchyAPIClient,
 		svc.ServiceAuthToken,
 		svc.Elasticsearch,
+		svc.OrphanCollector,
+		svc.MissingIndexReconciler,
 		svc.DefaultMaxResults,
 		svc.HasPrivateEndpoints,
 		svc.HealthCheck,
@@ -79,6 +93,30 @@ func (svc *Service) Start(ctx context.Context) {
 		}
 	}()
 
+	backgroundCtx, stopBackgroundTasks := context.WithCancel(ctx)
+	go svc.manageIndexTemplate(backgroundCtx)
+
+	// only the private instance can see unpublished instances, which the web instance would take to be deleted
+	if svc.HasPrivateEndpoints {
+		if svc.OrphanedIndexGCInterval > 0 {
+			log.Info(ctx, "starting orphaned index collection", log.Data{"interval": svc.OrphanedIndexGCInterval.String(), "dry_run": svc.OrphanedIndexGCDryRun})
+			go svc.OrphanCollector.Run(backgroundCtx, svc.OrphanedIndexGCInterval, svc.OrphanedIndexGCDryRun)
+		}
+
+		go svc.MissingIndexReconciler.Run(backgroundCtx)
+	}
+
+	if svc.Outbox != nil {
+		log.Info(ctx, "starting outbox relay", log.Data{"pending": svc.Outbox.Pending()})
+		go svc.Outbox.Run(backgroundCtx)
+	}
+
+	if svc.InstanceRemovedConsumer != nil {
+		if err := svc.InstanceRemovedConsumer.Start(); err != nil {
+			log.Error(ctx, "error starting instance removed kafka consumer", err)
+		}
+	}
+
 	<-signals
 	log.Info(ctx, "os signal received")
 
@@ -86,12 +124,38 @@ func (svc *Service) Start(ctx context.Context) {
 	log.Info(ctx, fmt.Sprintf("shutdown with timeout: %s", svc.Shutdown))
 	ctx, cancel := context.WithTimeout(context.Background(), svc.Shutdown)
 
-	// stop any incoming requests before closing any outbound connections
+	// stop any incoming requests and background tasks before closing any outbound connections
 	api.Close(ctx)
+	stopBackgroundTasks()
 	svc.HealthCheck.Stop()
 
-	if err := svc.HierarchyBuiltProducer.Close(ctx); err != nil {
-		log.Error(ctx, "error while attempting to shutdown hierarchy built kafka producer", err)
+	// let any events being handled finish deleting search indexes before leaving the consumer group
+	if svc.InstanceRemovedConsumer != nil {
+		if err := svc.InstanceRemovedConsumer.StopAndWait(); err != nil {
+			log.Error(ctx, "error while attempting to stop instance removed kafka consumer", err)
+		}
+		if err := svc.InstanceRemovedConsumer.Close(ctx); err != nil {
+			log.Error(ctx, "error while attempting to shutdown instance removed kafka consumer", err)
+		}
+	}
+
+	if svc.HierarchyBuiltProducer != nil {
+		if err := svc.HierarchyBuiltProducer.Close(ctx); err != nil {
+			log.Error(ctx, "error while attempting to shutdown hierarchy built kafka producer", err)
+		}
+	}
+
+	if svc.PriorityProducer != nil {
+		if err := svc.PriorityProducer.Close(ctx); err != nil {
+			log.Error(ctx, "error while attempting to shutdown priority kafka producer", err)
+		}
+	}
+
+	// the confirmed delivery output queue and outbox have their own producer, and the file output queue its own file
+	if closer, ok := svc.OutputQueue.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Error(ctx, "error while attempting to shutdown output queue", err)
+		}
 	}
 
 	log.Info(ctx, "shutdown complete")
@@ -99,3 +163,30 @@ func (svc *Service) Start(ctx context.Context) {
 	cancel()
 	os.Exit(0)
 }
+
+// manageIndexTemplate installs the index template, or when installation is disabled or this is the web
+// instance only checks it, logging any drift from the expected mapping
+func (svc *Service) manageIndexTemplate(ctx context.Context) {
+	// only the private instance writes to the cluster, so the web instance cannot race it to install the template
+	install := svc.InstallIndexTemplate && svc.HasPrivateEndpoints
+
+	manage := svc.Elasticsearch.CheckTemplate
+	if install {
+		manage = svc.Elasticsearch.InstallTemplate
+	}
+
+	report, err := manage(ctx)
+	if err != nil {
+		log.Error(ctx, "failed to manage index template", err, log.Data{"install": install})
+		return
+	}
+
+	logData := log.Data{"template": report.Name, "version": report.Version, "installed_version": report.InstalledVersion, "updated": report.Updated}
+	if len(report.Drift) > 0 {
+		logData["drift"] = report.Drift
+		log.Warn(ctx, "index template or search index mappings differ from the expected mapping", logData)
+		return
+	}
+
+	log.Info(ctx, "index template and search index mappings are as expected", logData)
+}